@@ -0,0 +1,351 @@
+// 批量获取
+// GetMany 一次获取多个键：命中缓存的键直接在本地应答，未命中的键按所有者分组，
+// 每个远程节点只发送一次批量请求，本节点拥有的键则尽量通过 BatchGetter 一次加载。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// BatchGetter 是 Getter 的可选扩展，用于一次加载多个键。
+// 未实现该接口的 Getter 会对每个键分别调用 Get。
+type BatchGetter interface {
+	Getter
+	// GetMany 加载 keys 对应的数据并填充 dests，dests 与 keys 一一对应。
+	// 全部成功时返回 nil，否则返回的切片与 keys 一一对应，nil 元素表示该键加载成功。
+	GetMany(ctx context.Context, keys []string, dests []Sink) []error
+}
+
+// GetMany 获取 keys 对应的数据，并按 keys 的顺序对每个键调用一次 fn，err 非 nil 时 value 无意义。
+// fn 在 GetMany 返回之前于调用方的协程中被调用。
+// 与 Get 一样，同一个键的并发加载（包括与 Get 之间）会通过 loadGroup 去重。
+func (g *Group) GetMany(ctx context.Context, keys []string, fn func(key string, value ByteView, err error)) {
+	g.peersOnce.Do(g.initPeers)
+	defer g.flushEvictions()
+
+	values := make([]ByteView, len(keys))
+	errs := make([]error, len(keys))
+	var misses []string
+	var missIdx []int
+	for i, key := range keys {
+		g.Stats.Gets.Add(1)
+		g.hotKeys.Add(key)
+		if value, src, ok := g.lookupCache(key); ok {
+			g.onCacheHit(key, value, src)
+			values[i] = value
+			continue
+		}
+		g.emit(eventMiss, Event{Key: key})
+		if err, ok := g.lookupNegative(key); ok {
+			errs[i] = err
+			continue
+		}
+		misses = append(misses, key)
+		missIdx = append(missIdx, i)
+	}
+
+	if len(misses) > 0 {
+		g.Stats.Loads.Add(int64(len(misses)))
+		vals, loadErrs := g.loadGroup.DoMany(misses, func(keys []string) ([]interface{}, []error) {
+			return g.loadMany(ctx, keys)
+		})
+		for j, i := range missIdx {
+			if loadErrs[j] != nil {
+				errs[i] = loadErrs[j]
+				continue
+			}
+			values[i] = vals[j].(ByteView)
+		}
+	}
+
+	for i, key := range keys {
+		fn(key, values[i], errs[i])
+	}
+}
+
+// peerBatch 是发往同一个远程节点的一批键在 keys 中的下标
+type peerBatch struct {
+	peer ProtoGetter
+	idx  []int
+}
+
+// loadMany 是 load 的批量版本，由 loadGroup.DoMany 调用，返回值与 keys 一一对应
+func (g *Group) loadMany(ctx context.Context, keys []string) ([]interface{}, []error) {
+	vals := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	gen := g.generation()
+
+	// 与 load 一样，先再次检查缓存，再按所有者分组
+	batches := make(map[string]*peerBatch)
+	var local []int
+	for i, key := range keys {
+		if value, _, ok := g.lookupCache(key); ok && !g.isStale(value) {
+			g.Stats.CacheHits.Add(1)
+			vals[i] = value
+			continue
+		}
+		g.Stats.LoadsDeduped.Add(1)
+		if peer, ok := g.pickOwner(key); ok {
+			b := batches[peer.GetURL()]
+			if b == nil {
+				b = &peerBatch{peer: peer}
+				batches[peer.GetURL()] = b
+			}
+			b.idx = append(b.idx, i)
+			continue
+		}
+		local = append(local, i)
+	}
+
+	// 各远程节点和本地加载并发进行，远程请求失败的键随后在本地重新加载
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		fallback []int
+	)
+	for _, b := range batches {
+		wg.Add(1)
+		go func(b *peerBatch) {
+			defer wg.Done()
+			retry := g.fetchManyFromPeer(ctx, b.peer, gen, keys, b.idx, vals, errs)
+			// 与 fetch 相同，开启复制时先向其余副本请求，仍然失败的键再按 PeerFallback 决定是否在本地加载
+			var reload []int
+			for _, i := range retry {
+				if value, ok := g.getFromReplicas(ctx, keys[i], gen, b.peer); ok {
+					vals[i], errs[i] = value, nil
+					continue
+				}
+				if _, self := g.replicaOwners(keys[i]); g.peerFallback == PeerFallbackFail && !self {
+					continue
+				}
+				reload = append(reload, i)
+			}
+			if len(reload) > 0 {
+				mu.Lock()
+				fallback = append(fallback, reload...)
+				mu.Unlock()
+			}
+		}(b)
+	}
+	if len(local) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.fetchManyLocally(ctx, gen, keys, local, vals, errs)
+		}()
+	}
+	wg.Wait()
+	if len(fallback) > 0 {
+		g.fetchManyLocally(ctx, gen, keys, fallback, vals, errs)
+	}
+	return vals, errs
+}
+
+// fetchManyFromPeer 通过一次批量请求从 peer 获取 keys 中下标为 idx 的键，结果写入 vals 和 errs 的对应位置。
+// peer 不支持批量请求时退化为逐个并发获取。
+// 与 fetch 相同，远程请求出错且上下文仍然有效时，返回需要改从其他节点获取的下标，errs 中对应的位置是该错误。
+// gen 是开始加载时组的代数。
+func (g *Group) fetchManyFromPeer(ctx context.Context, peer ProtoGetter, gen uint64, keys []string, idx []int, vals []interface{}, errs []error) (retry []int) {
+	bp, ok := peer.(BatchProtoGetter)
+	if !ok {
+		var wg sync.WaitGroup
+		for _, i := range idx {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var view ByteView
+				value, _, err := g.fetch(ctx, keys[i], ByteViewSink(&view))
+				vals[i], errs[i] = value, err
+			}(i)
+		}
+		wg.Wait()
+		return nil
+	}
+
+	req := &pb.GetManyRequest{
+		Group:      g.name,
+		Keys:       make([]string, len(idx)),
+		Generation: gen,
+	}
+	for j, i := range idx {
+		req.Keys[j] = keys[i]
+	}
+	res := &pb.GetManyResponse{}
+
+	start := time.Now()
+	err := g.callPeer(ctx, peer, func() error {
+		return bp.GetMany(ctx, req, res)
+	})
+	if err == nil && len(res.Results) != len(idx) {
+		err = fmt.Errorf("peer returned %d results for %d keys", len(res.Results), len(idx))
+	}
+	duration := time.Since(start)
+	if g.Stats.GetFromPeersLatencyLower.Get() < int64(duration/time.Millisecond) {
+		g.Stats.GetFromPeersLatencyLower.Store(int64(duration / time.Millisecond))
+	}
+
+	if err != nil {
+		for _, i := range idx {
+			g.emit(eventLoad, Event{Key: keys[i], Source: SourcePeer, Duration: duration, Err: err})
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, &ErrNotFound{}) || errors.Is(err, &ErrRemoteCall{}) {
+			for _, i := range idx {
+				errs[i] = err
+			}
+			return nil
+		}
+		if logger := g.getLogger(); logger != nil && !errors.Is(err, ErrCircuitOpen) {
+			logger.Error().
+				WithFields(map[string]interface{}{
+					"err":      err,
+					"keys":     len(idx),
+					"category": "groupcache",
+				}).Printf("error retrieving keys from peer '%s'", peer.GetURL())
+		}
+		g.Stats.PeerErrors.Add(1)
+		for _, i := range idx {
+			errs[i] = err
+		}
+		if ctx != nil && ctx.Err() != nil {
+			return nil
+		}
+		return idx
+	}
+
+	// 一次批量请求的耗时由其中的所有键分摊
+	g.observePeerLoad(duration / time.Duration(len(idx)))
+	for j, i := range idx {
+		r := res.Results[j]
+		switch {
+		case r.NotFound:
+			errs[i] = &ErrNotFound{Msg: r.Error}
+			g.populateNegative(keys[i], errs[i])
+		case r.Error != "":
+			errs[i] = &ErrRemoteCall{Msg: r.Error}
+		case r.Value == nil:
+			errs[i] = errors.New("peer returned no value")
+		default:
+			value, err := g.acceptPeerResponse(keys[i], r.Value, gen)
+			if err != nil {
+				errs[i] = err
+				break
+			}
+			g.Stats.PeerLoads.Add(1)
+			vals[i] = value
+		}
+		ev := Event{Key: keys[i], Source: SourcePeer, Duration: duration, Err: errs[i]}
+		if v, ok := vals[i].(ByteView); ok {
+			ev.Size = v.Len()
+		}
+		g.emit(eventLoad, ev)
+	}
+	return nil
+}
+
+// fetchManyLocally 在本地加载 keys 中下标为 idx 的键，结果写入 vals 和 errs 的对应位置。
+// getter 实现了 BatchGetter 时一次加载完成，否则对每个键并发调用 Get。gen 是开始加载时组的代数。
+func (g *Group) fetchManyLocally(ctx context.Context, gen uint64, keys []string, idx []int, vals []interface{}, errs []error) {
+	// 与 fetch 相同，本节点是副本之一的键先向其他副本查找
+	if g.replicas > 1 {
+		found := make([]bool, len(idx))
+		var wg sync.WaitGroup
+		for j, i := range idx {
+			wg.Add(1)
+			go func(j, i int) {
+				defer wg.Done()
+				if value, ok := g.getFromSiblings(ctx, keys[i], gen); ok {
+					vals[i], errs[i] = value, nil
+					found[j] = true
+				}
+			}(j, i)
+		}
+		wg.Wait()
+		rest := idx[:0:0]
+		for j, i := range idx {
+			if !found[j] {
+				rest = append(rest, i)
+			}
+		}
+		if idx = rest; len(idx) == 0 {
+			return
+		}
+	}
+
+	batchKeys := make([]string, len(idx))
+	views := make([]ByteView, len(idx))
+	dests := make([]Sink, len(idx))
+	for j, i := range idx {
+		batchKeys[j] = keys[i]
+		dests[j] = ByteViewSink(&views[j])
+	}
+
+	ctx, cancel := g.loadContext(ctx)
+	defer cancel()
+	start := time.Now()
+	seq := atomic.LoadUint64(&g.storeSeq)
+	var loadErrs []error
+	// 有键尚未写入数据源时逐个加载，这些键以 write-behind 队列中的操作为准
+	if bg, ok := g.getter.(BatchGetter); ok && !g.hasPendingWrites(batchKeys) {
+		if release, err := g.acquireLoad(ctx, len(batchKeys)); err != nil {
+			loadErrs = make([]error, len(idx))
+			for j := range loadErrs {
+				loadErrs[j] = err
+			}
+		} else {
+			loadErrs = bg.GetMany(ctx, batchKeys, dests)
+			release()
+		}
+		if loadErrs != nil && len(loadErrs) != len(idx) {
+			err := fmt.Errorf("BatchGetter returned %d errors for %d keys", len(loadErrs), len(idx))
+			loadErrs = make([]error, len(idx))
+			for j := range loadErrs {
+				loadErrs[j] = err
+			}
+		}
+	} else {
+		loadErrs = make([]error, len(idx))
+		var wg sync.WaitGroup
+		for j := range batchKeys {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				loadErrs[j] = g.getFromStore(ctx, batchKeys[j], dests[j])
+			}(j)
+		}
+		wg.Wait()
+	}
+	duration := time.Since(start)
+	g.observeLocalLoad(duration / time.Duration(len(idx)))
+
+	for j, i := range idx {
+		var err error
+		if loadErrs != nil {
+			err = loadErrs[j]
+		}
+		g.emit(eventLoad, Event{Key: keys[i], Size: views[j].Len(), Source: SourceGetter, Duration: duration, Err: err})
+		if err != nil {
+			g.Stats.LocalLoadErrs.Add(1)
+			if errors.Is(err, &ErrNotFound{}) {
+				g.populateNegative(keys[i], err)
+			}
+			errs[i] = err
+			continue
+		}
+		g.Stats.LocalLoads.Add(1)
+		value := g.withDefaultTTL(views[j])
+		value.gen = gen
+		g.populateLoaded(keys[i], value, seq)
+		if _, self := g.replicaOwners(keys[i]); self {
+			g.replicate(keys[i], value, nil)
+		}
+		vals[i], errs[i] = value, nil
+	}
+}
@@ -0,0 +1,99 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// batchPeer 是实现了 BatchProtoGetter 的 fakePeer，键 "rmissing" 不存在
+type batchPeer struct {
+	fakePeer
+	batches, keys int32
+}
+
+func (p *batchPeer) GetMany(ctx context.Context, in *pb.GetManyRequest, out *pb.GetManyResponse) error {
+	atomic.AddInt32(&p.batches, 1)
+	atomic.AddInt32(&p.keys, int32(len(in.Keys)))
+	for _, k := range in.Keys {
+		if k == "rmissing" {
+			out.Results = append(out.Results, &pb.GetManyResult{NotFound: true, Error: "no such key"})
+			continue
+		}
+		out.Results = append(out.Results, &pb.GetManyResult{Value: &pb.GetResponse{Value: []byte("peer:" + k)}})
+	}
+	return nil
+}
+
+// batchGetter 是实现了 BatchGetter 的 Getter，键 "lmissing" 不存在
+type batchGetter struct{ batches, singles int32 }
+
+func (b *batchGetter) Get(ctx context.Context, key string, dest Sink) error {
+	atomic.AddInt32(&b.singles, 1)
+	return dest.SetString("v:"+key, time.Time{})
+}
+
+func (b *batchGetter) GetMany(ctx context.Context, keys []string, dests []Sink) []error {
+	atomic.AddInt32(&b.batches, 1)
+	errs := make([]error, len(keys))
+	for i, k := range keys {
+		if k == "lmissing" {
+			errs[i] = &ErrNotFound{Msg: "no such key"}
+			continue
+		}
+		errs[i] = dests[i].SetString("v:"+k, time.Time{})
+	}
+	return errs
+}
+
+func TestGetMany(t *testing.T) {
+	peer := &batchPeer{fakePeer: fakePeer{url: "owner"}}
+	bg := &batchGetter{}
+	g := newTestGroup(t, bg, WithPeerPicker(prefixPicker{peer}))
+	ctx := context.Background()
+
+	got := map[string]string{}
+	var calls int
+	g.GetMany(ctx, []string{"l1", "r1", "l2", "r2", "lmissing", "rmissing", "l1"}, func(key string, v ByteView, err error) {
+		calls++
+		if err != nil {
+			if !errors.Is(err, &ErrNotFound{}) {
+				t.Errorf("GetMany(%s) error = %v, want ErrNotFound", key, err)
+			}
+			got[key] = "missing"
+			return
+		}
+		got[key] = v.String()
+	})
+	want := map[string]string{
+		"l1": "v:l1", "l2": "v:l2", "r1": "peer:r1", "r2": "peer:r2",
+		"lmissing": "missing", "rmissing": "missing",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("GetMany(%s) = %q, want %q", k, got[k], v)
+		}
+	}
+	if calls != 7 {
+		t.Errorf("fn called %d times, want once per key", calls)
+	}
+	// 本地键通过一次 BatchGetter 调用加载，远程键合并为一次批量请求
+	if bg.batches != 1 || bg.singles != 0 || peer.batches != 1 || peer.keys != 3 || peer.gets != 0 {
+		t.Fatalf("batches = %d, singles = %d, peer batches = %d, peer keys = %d, peer gets = %d",
+			bg.batches, bg.singles, peer.batches, peer.keys, peer.gets)
+	}
+
+	// 再次获取时命中缓存
+	g.GetMany(ctx, []string{"l1", "r1"}, func(key string, v ByteView, err error) {
+		if err != nil {
+			t.Fatalf("GetMany(%s) error = %v", key, err)
+		}
+	})
+	if bg.batches != 1 || peer.batches != 1 {
+		t.Fatalf("cached keys loaded again: batches = %d, peer batches = %d", bg.batches, peer.batches)
+	}
+}
@@ -0,0 +1,295 @@
+// 远程调用的熔断与重试
+// 所有者节点不稳定时，每次失败都会退回本地加载，集群中的各个节点会因此重复回源。
+// 每个远程节点对应一个熔断器：连续失败达到阈值后打开，一段时间内不再请求该节点；
+// 之后进入半开状态放行一个探测请求，成功则关闭，失败则重新打开。
+// 可重试的失败按带随机抖动的指数退避重试，熔断打开或重试失败后的处理方式由 PeerFallback 决定。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 表示远程节点的熔断器处于打开状态，请求没有发出
+var ErrCircuitOpen = errors.New("peer circuit breaker is open")
+
+// BreakerState 表示熔断器的状态
+type BreakerState int
+
+const (
+	// BreakerClosed 表示请求正常发出
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen 表示请求被直接拒绝
+	BreakerOpen
+
+	// BreakerHalfOpen 表示正在放行一个探测请求
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerOptions 是熔断器的配置，为零的字段使用默认值
+type BreakerOptions struct {
+	// FailureThreshold 是打开熔断器所需的连续失败次数，默认为 5
+	FailureThreshold int
+
+	// OpenTimeout 是熔断器打开后进入半开状态之前的时间，默认为 10s
+	OpenTimeout time.Duration
+}
+
+// RetryOptions 是远程调用的重试策略，为零的字段使用默认值
+type RetryOptions struct {
+	// MaxAttempts 是包括第一次在内的最大尝试次数，默认为 1，即不重试
+	MaxAttempts int
+
+	// Backoff 是第一次重试前的等待时间，之后每次翻倍并加入随机抖动，默认为 50ms
+	Backoff time.Duration
+
+	// MaxBackoff 是重试等待时间的上限，默认为 1s
+	MaxBackoff time.Duration
+}
+
+// PeerFallback 决定远程节点不可用（熔断打开或重试后仍失败）时如何获取数据
+type PeerFallback int
+
+const (
+	// PeerFallbackLocal 在本地调用 Getter 加载，这是默认行为
+	PeerFallbackLocal PeerFallback = iota
+
+	// PeerFallbackFail 直接返回错误，避免所有者不可用时各节点同时回源
+	PeerFallbackFail
+)
+
+// BreakerStats 是一个远程节点的熔断器的状态
+type BreakerStats struct {
+	Peer  string
+	State BreakerState
+
+	// Failures 是当前连续失败的次数
+	Failures int
+
+	// Opens 是熔断器累计打开的次数
+	Opens int64
+}
+
+// WithPeerBreaker 为每个远程节点开启熔断器
+func WithPeerBreaker(opts BreakerOptions) GroupOption {
+	return func(g *Group) {
+		if opts.FailureThreshold <= 0 {
+			opts.FailureThreshold = 5
+		}
+		if opts.OpenTimeout <= 0 {
+			opts.OpenTimeout = 10 * time.Second
+		}
+		g.breakerOpts = &opts
+	}
+}
+
+// WithPeerRetry 设置远程调用失败后的重试策略
+func WithPeerRetry(opts RetryOptions) GroupOption {
+	return func(g *Group) {
+		if opts.MaxAttempts <= 0 {
+			opts.MaxAttempts = 1
+		}
+		if opts.Backoff <= 0 {
+			opts.Backoff = 50 * time.Millisecond
+		}
+		if opts.MaxBackoff <= 0 {
+			opts.MaxBackoff = time.Second
+		}
+		g.peerRetry = opts
+	}
+}
+
+// WithPeerFallback 设置远程节点不可用时的处理方式
+func WithPeerFallback(fallback PeerFallback) GroupOption {
+	return func(g *Group) {
+		g.peerFallback = fallback
+	}
+}
+
+// PeerBreakers 返回已请求过的各远程节点的熔断器状态，未开启熔断器时返回 nil
+func (g *Group) PeerBreakers() []BreakerStats {
+	if g.breakerOpts == nil {
+		return nil
+	}
+	var stats []BreakerStats
+	g.breakers.Range(func(k, v interface{}) bool {
+		stats = append(stats, v.(*circuitBreaker).stats(k.(string)))
+		return true
+	})
+	return stats
+}
+
+// breaker 返回 peer 对应的熔断器，未开启熔断器时返回 nil
+func (g *Group) breaker(peer ProtoGetter) *circuitBreaker {
+	if g.breakerOpts == nil {
+		return nil
+	}
+	url := peer.GetURL()
+	if b, ok := g.breakers.Load(url); ok {
+		return b.(*circuitBreaker)
+	}
+	b, _ := g.breakers.LoadOrStore(url, &circuitBreaker{opts: *g.breakerOpts})
+	return b.(*circuitBreaker)
+}
+
+// peerHealthy 判断 peer 是否可以接收请求，未开启熔断器时总是返回 true
+func (g *Group) peerHealthy(peer ProtoGetter) bool {
+	b := g.breaker(peer)
+	return b == nil || b.healthy()
+}
+
+// peerFailure 判断一次远程调用的错误是否说明节点不可用。
+// 节点正常应答的 ErrNotFound 和 ErrRemoteCall 不算，调用方自己取消或超时也不算。
+func peerFailure(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, &ErrNotFound{}) || errors.Is(err, &ErrRemoteCall{}) {
+		return false
+	}
+	return ctx == nil || ctx.Err() == nil
+}
+
+// callPeer 通过 peer 的熔断器和组的重试策略调用 fn，fn 每次尝试执行一次远程调用
+func (g *Group) callPeer(ctx context.Context, peer ProtoGetter, fn func() error) error {
+	b := g.breaker(peer)
+	attempts := g.peerRetry.MaxAttempts
+	backoff := g.peerRetry.Backoff
+	for attempt := 1; ; attempt++ {
+		if b != nil && !b.allow() {
+			g.Stats.BreakerRejects.Add(1)
+			return ErrCircuitOpen
+		}
+		err := fn()
+		failed := peerFailure(ctx, err)
+		if b != nil {
+			// 节点应答了 ErrNotFound 等业务错误也说明它可用，按成功计；只有调用方自己取消或超时时不计入
+			canceled := err != nil && ctx != nil && ctx.Err() != nil
+			if b.done(failed || !canceled, failed) {
+				g.Stats.BreakerOpens.Add(1)
+				if logger := g.getLogger(); logger != nil {
+					logger.Warn().
+						WithFields(map[string]interface{}{
+							"err":      err,
+							"category": "groupcache",
+						}).Printf("circuit breaker for peer '%s' opened", peer.GetURL())
+				}
+			}
+		}
+		if !failed || attempt >= attempts {
+			return err
+		}
+
+		g.Stats.PeerRetries.Add(1)
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+		if backoff > g.peerRetry.MaxBackoff {
+			backoff = g.peerRetry.MaxBackoff
+		}
+	}
+}
+
+// circuitBreaker 是一个远程节点的熔断器
+type circuitBreaker struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	opens    int64
+	// probing 表示半开状态下已经放行了探测请求
+	probing bool
+}
+
+// allow 判断是否可以发出请求，打开状态超过 OpenTimeout 后转为半开并放行一个探测请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if NowFunc().Sub(b.openedAt) < b.opts.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// healthy 判断熔断器是否会放行请求，与 allow 不同，它不改变熔断器的状态
+func (b *circuitBreaker) healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != BreakerOpen || NowFunc().Sub(b.openedAt) >= b.opts.OpenTimeout
+}
+
+// done 记录一次请求的结果，counted 为 false 时（例如调用方取消）不影响状态。
+// 返回熔断器是否因此打开。
+func (b *circuitBreaker) done(counted, failed bool) (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !counted {
+		return false
+	}
+	if !failed {
+		b.state = BreakerClosed
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = NowFunc()
+		b.opens++
+		return true
+	}
+	return false
+}
+
+func (b *circuitBreaker) stats(peer string) BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{
+		Peer:     peer,
+		State:    b.state,
+		Failures: b.failures,
+		Opens:    b.opens,
+	}
+}
@@ -0,0 +1,174 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+func TestPeerBreaker(t *testing.T) {
+	now := time.Now()
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = time.Now }()
+
+	var down atomic.Bool
+	down.Store(true)
+	peer := &fakePeer{url: "p1", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		if down.Load() {
+			return errPeerDown
+		}
+		out.Value = []byte("peer")
+		return nil
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithCacheBytes(0),
+		WithPeerPicker(fixedPicker{peer}),
+		WithPeerBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Second}),
+		WithPeerRetry(RetryOptions{MaxAttempts: 2, Backoff: time.Millisecond}))
+	ctx := context.Background()
+
+	// 两次尝试都失败后熔断器打开，并在本地加载
+	var s string
+	if err := g.Get(ctx, "a", StringSink(&s)); err != nil || s != "v:a" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if peer.gets != 2 || g.Stats.PeerRetries.Get() != 1 {
+		t.Fatalf("peer gets = %d, retries = %d", peer.gets, g.Stats.PeerRetries.Get())
+	}
+	if bs := g.PeerBreakers(); len(bs) != 1 || bs[0].State != BreakerOpen || bs[0].Opens != 1 {
+		t.Fatalf("breakers = %+v", bs)
+	}
+
+	// 打开期间不再请求该节点
+	if err := g.Get(ctx, "b", StringSink(&s)); err != nil || s != "v:b" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if peer.gets != 2 || g.Stats.BreakerRejects.Get() != 1 {
+		t.Fatalf("peer gets = %d, rejects = %d", peer.gets, g.Stats.BreakerRejects.Get())
+	}
+
+	// OpenTimeout 之后放行探测请求，成功则关闭
+	now = now.Add(2 * time.Second)
+	down.Store(false)
+	if err := g.Get(ctx, "c", StringSink(&s)); err != nil || s != "peer" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if bs := g.PeerBreakers(); bs[0].State != BreakerClosed || bs[0].Failures != 0 {
+		t.Fatalf("breakers = %+v", bs)
+	}
+}
+
+func TestPeerFallbackFail(t *testing.T) {
+	peer := &fakePeer{url: "p1", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		return errPeerDown
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithCacheBytes(0),
+		WithPeerPicker(fixedPicker{peer}),
+		WithPeerFallback(PeerFallbackFail))
+	var s string
+	if err := g.Get(context.Background(), "a", StringSink(&s)); !errors.Is(err, errPeerDown) {
+		t.Fatalf("Get error = %v, want the peer error", err)
+	}
+	if loads != 0 {
+		t.Fatalf("getter called %d times, want 0", loads)
+	}
+	if g.PeerBreakers() != nil {
+		t.Fatal("PeerBreakers should be nil without WithPeerBreaker")
+	}
+}
+
+// 所有者正常应答的 ErrNotFound 和加载失败既不重试，也不计入熔断器
+func TestPeerBreakerIgnoresRemoteErrors(t *testing.T) {
+	var owner *Group
+	var ownerLoads int32
+	owner = newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		atomic.AddInt32(&ownerLoads, 1)
+		if key == "missing" {
+			return &ErrNotFound{Msg: "no such key"}
+		}
+		return errors.New("db down")
+	}), WithCacheBytes(0))
+	peer := &nodePeer{url: "owner", g: owner}
+
+	var loads int32
+	g := NewGroupWithOptions(owner.Name()+"_client", countingGetter(&loads),
+		WithCacheBytes(0),
+		WithPeerPicker(fixedPicker{peer}),
+		WithPeerBreaker(BreakerOptions{FailureThreshold: 1}),
+		WithPeerRetry(RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond}))
+	defer DeregisterGroup(g.Name())
+
+	ctx := context.Background()
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(ctx, "missing", StringSink(&s)); !errors.Is(err, &ErrNotFound{}) {
+			t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+		}
+		if err := g.Get(ctx, "broken", StringSink(&s)); !errors.Is(err, &ErrRemoteCall{}) {
+			t.Fatalf("Get(broken) error = %v, want ErrRemoteCall", err)
+		}
+	}
+	if peer.gets != 6 || ownerLoads != 6 {
+		t.Fatalf("peer gets = %d, owner loads = %d, want 6 each", peer.gets, ownerLoads)
+	}
+	if loads != 0 || g.Stats.PeerRetries.Get() != 0 || g.Stats.BreakerOpens.Get() != 0 {
+		t.Fatalf("local loads = %d, retries = %d, opens = %d", loads, g.Stats.PeerRetries.Get(), g.Stats.BreakerOpens.Get())
+	}
+	if bs := g.PeerBreakers(); len(bs) != 1 || bs[0].State != BreakerClosed {
+		t.Fatalf("breakers = %+v", bs)
+	}
+}
+
+// 半开状态下探测请求得到 ErrNotFound 说明节点可用，熔断器关闭；业务错误也会清零连续失败计数
+func TestPeerBreakerHalfOpenNotFound(t *testing.T) {
+	now := time.Now()
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = time.Now }()
+
+	var down atomic.Bool
+	peer := &fakePeer{url: "p1", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		if down.Load() {
+			return errPeerDown
+		}
+		return fromRPCError(rpcError(&ErrNotFound{Msg: "no such key"}), "get")
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithCacheBytes(0),
+		WithPeerPicker(fixedPicker{peer}),
+		WithPeerBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Second}))
+	ctx := context.Background()
+	var s string
+
+	down.Store(true)
+	g.Get(ctx, "a", StringSink(&s))
+	down.Store(false)
+	if err := g.Get(ctx, "b", StringSink(&s)); !errors.Is(err, &ErrNotFound{}) {
+		t.Fatalf("Get = %v, want ErrNotFound", err)
+	}
+	if bs := g.PeerBreakers(); bs[0].State != BreakerClosed || bs[0].Failures != 0 {
+		t.Fatalf("breakers = %+v, want the failure count reset", bs)
+	}
+
+	down.Store(true)
+	g.Get(ctx, "c", StringSink(&s))
+	g.Get(ctx, "d", StringSink(&s))
+	if bs := g.PeerBreakers(); bs[0].State != BreakerOpen {
+		t.Fatalf("breakers = %+v, want open", bs)
+	}
+	now = now.Add(2 * time.Second)
+	down.Store(false)
+	if err := g.Get(ctx, "e", StringSink(&s)); !errors.Is(err, &ErrNotFound{}) {
+		t.Fatalf("probe Get = %v, want ErrNotFound", err)
+	}
+	if bs := g.PeerBreakers(); bs[0].State != BreakerClosed {
+		t.Fatalf("breakers = %+v, want closed after the probe", bs)
+	}
+}
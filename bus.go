@@ -0,0 +1,174 @@
+// 失效总线
+// Remove 逐个向 GetAll 返回的节点发送删除请求，某个节点暂时不可达时，它的 hotCache 会一直保留旧值。
+// 配置了 InvalidationBus 的组改为通过发布/订阅广播失效消息：Remove 和 Set 发布消息，每个节点订阅后清除本地的副本。
+// 消息带有连续递增的序号，节点断线重连后发现序号不连续，说明错过了消息，此时清空整个 hotCache。
+
+package geecache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// InvalidationOp 表示失效消息对应的操作
+type InvalidationOp int
+
+const (
+	// InvalidateRemove 表示键被 Remove 删除，订阅者清除该键在本地的所有副本
+	InvalidateRemove InvalidationOp = iota + 1
+
+	// InvalidateSet 表示键被 Set 设置了新值，订阅者清除该键在 hotCache 中的副本
+	InvalidateSet
+)
+
+// InvalidationMessage 是在失效总线上传递的消息。
+// Op 为零的消息只携带序号，总线用它告知订阅者当前的序号。
+type InvalidationMessage struct {
+	// Seq 是总线分配的序号，从 1 开始连续递增
+	Seq uint64 `json:"seq"`
+
+	Op  InvalidationOp `json:"op"`
+	Key string         `json:"key"`
+
+	// Origin 标识发布消息的节点，节点会忽略自己发布的消息
+	Origin string `json:"origin"`
+}
+
+// InvalidationBus 是在节点之间广播失效消息的发布/订阅通道，每个组使用一个独立的总线。
+// 实现需要为消息分配连续递增的序号，并按序号顺序投递给订阅者。
+type InvalidationBus interface {
+	// Publish 发布一条消息，msg.Seq 由总线分配
+	Publish(ctx context.Context, msg InvalidationMessage) error
+
+	// Subscribe 注册 fn 后立即返回，之后发布的消息在后台依次交给 fn，直到 ctx 被取消。
+	// 连接断开后由实现自行重连，无法补发的消息表现为序号不连续。
+	Subscribe(ctx context.Context, fn func(InvalidationMessage))
+}
+
+// WithInvalidationBus 在创建组时配置失效总线并开始订阅，订阅在 DeregisterGroup 时取消。
+// 配置后 Remove 不再逐个向其他节点发送删除请求，只向键的所有者发送，其余节点通过总线得知。
+func WithInvalidationBus(bus InvalidationBus) GroupOption {
+	return func(g *Group) {
+		// 重复配置时只保留最后一个总线的订阅
+		if g.busCancel != nil {
+			g.busCancel()
+		}
+		var b [8]byte
+		rand.Read(b[:])
+		ctx, cancel := context.WithCancel(context.Background())
+		g.bus = bus
+		g.busOrigin = hex.EncodeToString(b[:])
+		g.busCancel = cancel
+		bus.Subscribe(ctx, g.onInvalidation)
+	}
+}
+
+// publishInvalidation 在配置了失效总线时发布一条关于 key 的消息
+func (g *Group) publishInvalidation(ctx context.Context, op InvalidationOp, key string) error {
+	if g.bus == nil {
+		return nil
+	}
+	err := g.bus.Publish(ctx, InvalidationMessage{Op: op, Key: key, Origin: g.busOrigin})
+	if err != nil {
+		return fmt.Errorf("publish invalidation for key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// onInvalidation 处理从总线收到的消息，由订阅协程依次调用
+func (g *Group) onInvalidation(msg InvalidationMessage) {
+	if g.busSeq != 0 {
+		if msg.Seq <= g.busSeq {
+			return
+		}
+		// 序号不连续说明错过了消息，无法知道哪些键变了，只能清空 hotCache
+		if msg.Seq != g.busSeq+1 {
+			g.Stats.InvalidationGaps.Add(1)
+			g.clearHotCache()
+		}
+	}
+	g.busSeq = msg.Seq
+	if msg.Origin == g.busOrigin {
+		return
+	}
+	switch msg.Op {
+	case InvalidateRemove:
+		g.localRemove(msg.Key)
+	case InvalidateSet:
+		g.localRemoveHot(msg.Key)
+	}
+}
+
+// localRemoveHot 从 hotCache 中移除 key
+func (g *Group) localRemoveHot(key string) {
+	if g.CacheBytes() <= 0 {
+		return
+	}
+	g.loadGroup.Lock(func() {
+		g.hotCache.remove(key)
+	})
+	g.flushEvictions()
+}
+
+// clearHotCache 清空 hotCache
+func (g *Group) clearHotCache() {
+	g.loadGroup.Lock(func() {
+		g.hotCache.each(func(key string, v ByteView) bool {
+			g.hotCache.remove(key)
+			return true
+		})
+	})
+	g.flushEvictions()
+}
+
+// MemoryBus 是进程内的 InvalidationBus 实现，用于测试以及在同一进程中模拟多个节点。
+// Publish 在返回之前依次调用所有订阅者。
+type MemoryBus struct {
+	mu   sync.Mutex
+	seq  uint64
+	next int
+	subs map[int]func(InvalidationMessage)
+}
+
+// NewMemoryBus 创建一个进程内的失效总线
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[int]func(InvalidationMessage))}
+}
+
+// Publish 为 msg 分配序号并投递给所有订阅者
+func (b *MemoryBus) Publish(ctx context.Context, msg InvalidationMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	msg.Seq = b.seq
+	// 持有锁投递，保证所有订阅者按序号顺序收到消息
+	for _, fn := range b.subs {
+		fn(msg)
+	}
+	return nil
+}
+
+// Subscribe 注册 fn，ctx 被取消后注销
+func (b *MemoryBus) Subscribe(ctx context.Context, fn func(InvalidationMessage)) {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	b.mu.Unlock()
+
+	// 永不取消的上下文（例如 context.Background）不需要等待
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, id)
+		b.mu.Unlock()
+	}()
+}
+
+var _ InvalidationBus = (*MemoryBus)(nil)
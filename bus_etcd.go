@@ -0,0 +1,198 @@
+// 基于 etcd 的失效总线
+// 消息保存在 prefix/msg/<序号> 下，当前序号保存在 prefix/seq 中，发布时通过事务保证序号连续。
+// 订阅者 watch prefix/msg/，断线重连后先补读错过的消息，再从读取时的版本继续 watch。
+
+package geecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// defaultBusRetain 是 etcd 中保留的最近消息条数，更早的消息在发布新消息时被删除
+	defaultBusRetain = 1024
+
+	// busRetryInterval 是订阅出错后重连前的等待时间
+	busRetryInterval = time.Second
+)
+
+// EtcdBus 是基于 etcd 的 InvalidationBus 实现，每个组应当使用不同的 prefix，
+// 例如 "/geecache/invalidate/" + 组名 + "/"。
+type EtcdBus struct {
+	cli    *clientv3.Client
+	prefix string
+
+	// Retain 是 etcd 中保留的最近消息条数，重连时错过的消息超过该数量后无法补发，
+	// 订阅者会因序号不连续而清空 hotCache。为零时使用默认值 1024。
+	Retain uint64
+}
+
+// NewEtcdBus 创建一个使用 cli 访问 etcd、消息保存在 prefix 下的失效总线
+func NewEtcdBus(cli *clientv3.Client, prefix string) *EtcdBus {
+	return &EtcdBus{cli: cli, prefix: prefix}
+}
+
+// seqKey 返回保存当前序号的键
+func (b *EtcdBus) seqKey() string {
+	return b.prefix + "seq"
+}
+
+// msgPrefix 返回保存消息的键的前缀
+func (b *EtcdBus) msgPrefix() string {
+	return b.prefix + "msg/"
+}
+
+// msgKey 返回保存序号为 seq 的消息的键，序号补零到固定宽度，使键的字典序与序号顺序一致
+func (b *EtcdBus) msgKey(seq uint64) string {
+	return fmt.Sprintf("%s%020d", b.msgPrefix(), seq)
+}
+
+// retain 返回实际使用的保留条数
+func (b *EtcdBus) retain() uint64 {
+	if b.Retain == 0 {
+		return defaultBusRetain
+	}
+	return b.Retain
+}
+
+// Publish 以 prefix/seq 的当前值加一作为序号写入消息，并发发布时通过比较版本重试
+func (b *EtcdBus) Publish(ctx context.Context, msg InvalidationMessage) error {
+	for {
+		resp, err := b.cli.Get(ctx, b.seqKey())
+		if err != nil {
+			return err
+		}
+		var seq uint64
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			seq, err = strconv.ParseUint(string(resp.Kvs[0].Value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid bus sequence %q: %w", resp.Kvs[0].Value, err)
+			}
+			rev = resp.Kvs[0].ModRevision
+		}
+
+		msg.Seq = seq + 1
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		ops := []clientv3.Op{
+			clientv3.OpPut(b.seqKey(), strconv.FormatUint(msg.Seq, 10)),
+			clientv3.OpPut(b.msgKey(msg.Seq), string(data)),
+		}
+		if msg.Seq > b.retain() {
+			ops = append(ops, clientv3.OpDelete(b.msgKey(msg.Seq-b.retain())))
+		}
+		txn, err := b.cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(b.seqKey()), "=", rev)).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+		// 其他节点抢先发布了消息，重新读取序号
+	}
+}
+
+// Subscribe 在后台 watch 新消息，出错时等待 busRetryInterval 后重连
+func (b *EtcdBus) Subscribe(ctx context.Context, fn func(InvalidationMessage)) {
+	go func() {
+		var last uint64
+		first := true
+		for {
+			rev, err := b.catchUp(ctx, &last, first, fn)
+			if err == nil {
+				first = false
+				err = b.watch(ctx, rev, &last, fn)
+			}
+			if logger != nil && ctx.Err() == nil {
+				logger.Warn().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"prefix":   b.prefix,
+						"category": "groupcache",
+					}).Printf("invalidation bus subscription interrupted, reconnecting")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(busRetryInterval):
+			}
+		}
+	}()
+}
+
+// catchUp 读取当前序号以及序号大于 last 的消息，返回读取时的版本。
+// 首次订阅时只记录当前序号；重连时补发仍保存在 etcd 中的消息，
+// 无法补发的部分通过一条只携带序号的消息告知订阅者。
+func (b *EtcdBus) catchUp(ctx context.Context, last *uint64, first bool, fn func(InvalidationMessage)) (int64, error) {
+	resp, err := b.cli.Txn(ctx).Then(
+		clientv3.OpGet(b.seqKey()),
+		clientv3.OpGet(b.msgKey(*last+1), clientv3.WithRange(clientv3.GetPrefixRangeEnd(b.msgPrefix()))),
+	).Commit()
+	if err != nil {
+		return 0, err
+	}
+	var seq uint64
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+		seq, err = strconv.ParseUint(string(kvs[0].Value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid bus sequence %q: %w", kvs[0].Value, err)
+		}
+	}
+
+	if !first {
+		for _, kv := range resp.Responses[1].GetResponseRange().Kvs {
+			var msg InvalidationMessage
+			if err := json.Unmarshal(kv.Value, &msg); err != nil || msg.Seq <= *last {
+				continue
+			}
+			fn(msg)
+			*last = msg.Seq
+		}
+	}
+	if seq > *last {
+		fn(InvalidationMessage{Seq: seq})
+		*last = seq
+	}
+	return resp.Header.Revision, nil
+}
+
+// watch 从版本 rev 之后开始 watch 新消息，直到 ctx 被取消或连接出错
+func (b *EtcdBus) watch(ctx context.Context, rev int64, last *uint64, fn func(InvalidationMessage)) error {
+	// WithRequireLeader 使节点与 etcd 集群失联时 watch 立即出错，从而重连并补读消息
+	wch := b.cli.Watch(clientv3.WithRequireLeader(ctx), b.msgPrefix(), clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for wresp := range wch {
+		if err := wresp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range wresp.Events {
+			if ev.Type != clientv3.EventTypePut {
+				continue
+			}
+			var msg InvalidationMessage
+			if err := json.Unmarshal(ev.Kv.Value, &msg); err != nil || msg.Seq <= *last {
+				continue
+			}
+			fn(msg)
+			*last = msg.Seq
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.New("watch channel closed")
+}
+
+var _ InvalidationBus = (*EtcdBus)(nil)
@@ -0,0 +1,66 @@
+package geecache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInvalidationBus(t *testing.T) {
+	bus := NewMemoryBus()
+	var loads int32
+	a := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{}), WithInvalidationBus(bus))
+	b := NewGroupWithOptions(t.Name()+"_b", countingGetter(&loads), WithPeerPicker(fixedPicker{}), WithInvalidationBus(bus))
+	defer DeregisterGroup(b.Name())
+	ctx := context.Background()
+
+	// Set 只清除其他节点 hotCache 中的副本，发布者保留自己的新值
+	b.localSet("k", []byte("old"), time.Time{}, nil, &b.hotCache)
+	b.localSet("m", []byte("old"), time.Time{}, nil, &b.mainCache)
+	if err := a.Set(ctx, "k", []byte("new"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.hotCache.peek("k"); ok {
+		t.Fatal("hot copy survived Set")
+	}
+	if _, ok := a.mainCache.peek("k"); !ok {
+		t.Fatal("publisher dropped its own value")
+	}
+	if err := a.Remove(ctx, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.mainCache.peek("m"); ok {
+		t.Fatal("main copy survived Remove")
+	}
+
+	// 序号不连续时清空 hotCache
+	b.localSet("x", []byte("old"), time.Time{}, nil, &b.hotCache)
+	bus.mu.Lock()
+	bus.seq += 3
+	bus.mu.Unlock()
+	if err := a.Set(ctx, "y", []byte("v"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.hotCache.peek("x"); ok || b.Stats.InvalidationGaps.Get() != 1 {
+		t.Fatalf("gap not handled, gaps = %d", b.Stats.InvalidationGaps.Get())
+	}
+}
+
+// 重复配置只保留一个订阅，注销组后取消订阅
+func TestInvalidationBusSubscription(t *testing.T) {
+	bus := NewMemoryBus()
+	subs := func() int {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		return len(bus.subs)
+	}
+	var loads int32
+	g := NewGroupWithOptions(t.Name(), countingGetter(&loads), WithPeerPicker(fixedPicker{}),
+		WithInvalidationBus(bus), WithInvalidationBus(bus))
+	waitFor(t, "duplicate subscription removed", func() bool { return subs() == 1 })
+	if g.bus == nil {
+		t.Fatal("bus not configured")
+	}
+	DeregisterGroup(t.Name())
+	waitFor(t, "subscription cancelled", func() bool { return subs() == 0 })
+}
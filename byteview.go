@@ -27,6 +27,13 @@ type ByteView struct {
 	b []byte // b 将会存储真实的缓存值
 	s string
 	e time.Time
+	t time.Time // 加入本地缓存的时间，用于提前刷新
+
+	// enc 非 nil 时表示 b 是经 enc 压缩后的数据，只会出现在缓存内部，交给调用方之前会先解压
+	enc Compressor
+
+	tags []string // 加载或设置时附加的标签，用于按标签失效
+	gen  uint64   // 写入缓存时组的代数，小于组当前代数的条目视为已被 Purge 清除
 }
 
 // 返回与该视图关联的过期时间
@@ -34,6 +41,16 @@ func (v ByteView) Expire() time.Time {
 	return v.e
 }
 
+// hasTag 判断视图是否带有标签 tag
+func (v ByteView) hasTag(tag string) bool {
+	for _, t := range v.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // 实现value接口
 // Len returns the view's length
 func (v ByteView) Len() int {
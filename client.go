@@ -15,10 +15,13 @@ import (
 // 用于访问其他远程节点的客户端
 type client struct {
 	name string // 服务名称 geecache/ip:port
+
+	addr   string         // 节点的地址，用于报告健康状态
+	health *healthTracker // 为 nil 时不报告请求结果
 }
 
 // Get 从remote peer获取对应缓存值,借助 etcd 进行服务发现，通过 gRPC 进行通信，处理错误并返回结果
-func (c *client) Get(in *pb.GetRequest, out *pb.GetResponse) error {
+func (c *client) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
 	// 创建 etcd 客户端
 	cli, err := clientv3.New(defaultEtcdConfig)
 	if err != nil {
@@ -29,6 +32,7 @@ func (c *client) Get(in *pb.GetRequest, out *pb.GetResponse) error {
 	// 发现服务，取得与服务的连接
 	conn, err := registry.EtcdDial(cli, c.name)
 	if err != nil {
+		c.report(false)
 		return err
 	}
 	defer conn.Close()
@@ -36,27 +40,240 @@ func (c *client) Get(in *pb.GetRequest, out *pb.GetResponse) error {
 	// 创建 gRPC 客户端
 	grpcClient := pb.NewGeeCacheClient(conn)
 	// 构建 gRPC 请求上下文
-	// 创建了一个带有超时的上下文，确保 gRPC 请求在规定的时间内完成。
+	// 在调用方的上下文上加一个超时，确保 gRPC 请求在规定的时间内完成，调用方取消时请求也随之取消。
 	// defer cancel() 用于在函数返回前取消上下文，释放相关资源
-	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// 发起 gRPC 请求
 	resp, err := grpcClient.Get(ctx, &pb.GetRequest{
-		Group: in.Group,
-		Key: in.Key,
+		Group:      in.Group,
+		Key:        in.Key,
+		Generation: in.Generation,
+		CacheOnly:  in.CacheOnly,
 	})
+	c.report(!peerDown(err))
 	if err != nil {
-		return fmt.Errorf("could not get %s/%s from peer %s", in.Group, in.Key, c.name)
+		return fromRPCError(err, fmt.Sprintf("could not get %s/%s from peer %s", in.Group, in.Key, c.name))
 	}
 
 	out.Value = resp.GetValue()
+	out.Expire = resp.GetExpire()
+	out.MinuteQps = resp.GetMinuteQps()
+	out.Encoding = resp.GetEncoding()
+	out.Tags = resp.GetTags()
+	out.Generation = resp.GetGeneration()
+	return nil
+}
+
+// Set 请求 remote peer（key 的所有者）设置缓存值
+func (c *client) Set(ctx context.Context, in *pb.SetRequest) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = grpcClient.Set(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not set %s/%s on peer %s", in.Group, in.Key, c.name))
+	}
+	return nil
+}
+
+// Remove 请求 remote peer 删除缓存值
+func (c *client) Remove(ctx context.Context, in *pb.GetRequest) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = grpcClient.Remove(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not remove %s/%s from peer %s", in.Group, in.Key, c.name))
+	}
+	return nil
+}
+
+// GetURL 返回 remote peer 的服务名称
+func (c *client) GetURL() string {
+	return c.name
+}
+
+// GetMany 通过一次 gRPC 请求从 remote peer 获取多个缓存值
+func (c *client) GetMany(ctx context.Context, in *pb.GetManyRequest, out *pb.GetManyResponse) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := grpcClient.GetMany(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not get %d keys of group %s from peer %s", len(in.Keys), in.Group, c.name))
+	}
+	out.Results = resp.GetResults()
+	out.Generation = resp.GetGeneration()
 	return nil
 }
 
+// Invalidate 请求 remote peer 清除带有指定标签或以指定前缀开头的条目
+func (c *client) Invalidate(ctx context.Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := grpcClient.Invalidate(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not invalidate group %s on peer %s", in.Group, c.name))
+	}
+	out.Removed = resp.GetRemoved()
+	return nil
+}
+
+// Purge 通知 remote peer 切换到新的组代数
+func (c *client) Purge(ctx context.Context, in *pb.PurgeRequest, out *pb.PurgeResponse) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := grpcClient.Purge(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not purge group %s on peer %s", in.Group, c.name))
+	}
+	out.Generation = resp.GetGeneration()
+	return nil
+}
+
+// Replicate 把一个键的副本写入 remote peer 的 mainCache
+func (c *client) Replicate(ctx context.Context, in *pb.SetRequest) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	conn, err := registry.EtcdDial(cli, c.name)
+	if err != nil {
+		c.report(false)
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = grpcClient.Replicate(ctx, in)
+	c.report(!peerDown(err))
+	if err != nil {
+		return fromRPCError(err, fmt.Sprintf("could not replicate %s/%s to peer %s", in.Group, in.Key, c.name))
+	}
+	return nil
+}
+
+// Ping 向 remote peer 发送一次健康检查的探测请求
+func (c *client) Ping(ctx context.Context, in *pb.PingRequest) error {
+	cli, err := clientv3.New(defaultEtcdConfig)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	// 不可达的节点会使建立连接一直阻塞，因此连接也受 ctx 的限制
+	conn, err := registry.EtcdDialContext(ctx, cli, c.name)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	grpcClient := pb.NewGeeCacheClient(conn)
+	if _, err := grpcClient.Ping(ctx, in); err != nil {
+		return fmt.Errorf("could not ping peer %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// report 将一次请求的结果报告给健康检查
+func (c *client) report(ok bool) {
+	if c.health != nil {
+		c.health.observe(c.addr, ok, false)
+	}
+}
+
 func NewClient(service string) *client {
 	return &client{name: service}
 }
 
 // 测试 Client 是否实现了 PeerGetter 接口
 var _ ProtoGetter = (*client)(nil)
+var _ BatchProtoGetter = (*client)(nil)
+var _ Invalidator = (*client)(nil)
+var _ Purger = (*client)(nil)
+var _ Replicator = (*client)(nil)
+
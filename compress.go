@@ -0,0 +1,179 @@
+// 透明压缩
+// 开启后，超过阈值的值在进入 mainCache/hotCache 之前以及通过网络发送时会被压缩，
+// cacheBytes 按压缩后的大小计算；在数据交给调用方的 Sink 之前会自动解压。
+
+package geecache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor 是可插拔的压缩算法
+type Compressor interface {
+	// Name 返回压缩算法的名称，会写入 GetResponse.Encoding 和 SetRequest.Encoding，
+	// 接收方据此找到对应的 Compressor 解压
+	Name() string
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// FlateCompressor 使用 DEFLATE 压缩，Level 为零时使用 flate.DefaultCompression
+type FlateCompressor struct {
+	Level int
+}
+
+func (FlateCompressor) Name() string { return "flate" }
+
+func (c FlateCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, compressLevel(c.Level))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (FlateCompressor) Decompress(src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// GzipCompressor 使用 gzip 压缩，Level 为零时使用 gzip.DefaultCompression
+type GzipCompressor struct {
+	Level int
+}
+
+func (GzipCompressor) Name() string { return "gzip" }
+
+func (c GzipCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, compressLevel(c.Level))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressLevel 将零值映射为默认压缩级别（flate 和 gzip 的默认级别相同）
+func compressLevel(level int) int {
+	if level == 0 {
+		return flate.DefaultCompression
+	}
+	return level
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"flate": FlateCompressor{},
+		"gzip":  GzipCompressor{},
+	}
+)
+
+// RegisterCompressor 注册一个压缩算法，使本节点可以解压其他节点使用该算法压缩的数据。
+// flate 和 gzip 已默认注册；同名的注册会覆盖之前的。
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// compressorByName 返回名为 name 的压缩算法
+func compressorByName(name string) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown value encoding %q", name)
+	}
+	return c, nil
+}
+
+// compress 按组的配置压缩 b，返回压缩后的数据和所用算法的名称；不压缩时名称为空
+func (g *Group) compress(b []byte) ([]byte, string) {
+	c := g.compressor
+	if c == nil || len(b) < g.compressMin {
+		return b, ""
+	}
+	z, err := c.Compress(b)
+	if err != nil {
+		if logger := g.getLogger(); logger != nil {
+			logger.Error().
+				WithFields(map[string]interface{}{
+					"err":      err,
+					"group":    g.name,
+					"category": "groupcache",
+				}).Printf("error compressing value")
+		}
+		return b, ""
+	}
+	if len(z) >= len(b) {
+		return b, ""
+	}
+	return z, c.Name()
+}
+
+// decompress 按 encoding 解压 b，encoding 为空时原样返回
+func decompress(b []byte, encoding string) ([]byte, error) {
+	if encoding == "" {
+		return b, nil
+	}
+	c, err := compressorByName(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decompress(b)
+}
+
+// encodeView 返回 v 在缓存中保存的形式，已压缩的视图原样返回
+func (g *Group) encodeView(v ByteView) ByteView {
+	if v.enc != nil || g.compressor == nil || v.Len() < g.compressMin {
+		return v
+	}
+	z, name := g.compress(v.ByteSlice())
+	if name == "" {
+		return v
+	}
+	v.b, v.s, v.enc = z, "", g.compressor
+	return v
+}
+
+// decodeView 返回 v 解压后的视图，过期时间等元数据保持不变
+func decodeView(v ByteView) (ByteView, error) {
+	if v.enc == nil {
+		return v, nil
+	}
+	b, err := v.enc.Decompress(v.b)
+	if err != nil {
+		return ByteView{}, fmt.Errorf("decompress value: %w", err)
+	}
+	v.b, v.enc = b, nil
+	return v, nil
+}
@@ -0,0 +1,64 @@
+package geecache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+func TestCompression(t *testing.T) {
+	big := bytes.Repeat([]byte(`{"a":"hello world"},`), 500)
+	var loads int32
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetBytes(big, time.Time{})
+	}), WithPeerPicker(fixedPicker{}), WithCompression(GzipCompressor{}, 100))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		var out []byte
+		if err := g.Get(ctx, "k", AllocatingByteSliceSink(&out)); err != nil || !bytes.Equal(out, big) {
+			t.Fatalf("Get returned %d bytes, %v", len(out), err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("getter called %d times, want 1", loads)
+	}
+	// 缓存按压缩后的大小计算
+	if b := g.mainCache.bytes(); b >= int64(len(big)) {
+		t.Fatalf("mainCache holds %d bytes for a %d-byte value", b, len(big))
+	}
+	if v, ok := g.Peek("k"); !ok || !v.EqualBytes(big) {
+		t.Fatal("Peek did not return the decompressed value")
+	}
+
+	// 短于阈值的值不压缩
+	if z, enc := g.compress([]byte("short")); enc != "" || string(z) != "short" {
+		t.Fatalf("compress(short) = %q, %q", z, enc)
+	}
+}
+
+func TestCompressionFromPeer(t *testing.T) {
+	big := bytes.Repeat([]byte("xyzxyz"), 1000)
+	peer := &fakePeer{url: "owner", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		z, err := GzipCompressor{}.Compress(big)
+		out.Value, out.Encoding = z, "gzip"
+		return err
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{peer}), WithCompression(GzipCompressor{}, 10))
+
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := g.Get(context.Background(), "k", StringSink(&s)); err != nil || s != string(big) {
+			t.Fatalf("Get returned %d bytes, %v", len(s), err)
+		}
+	}
+	// 压缩的远程值原样进入 hotCache，命中时再解压
+	if peer.gets != 1 || g.hotCache.bytes() >= int64(len(big)) {
+		t.Fatalf("gets = %d, hotCache bytes = %d", peer.gets, g.hotCache.bytes())
+	}
+}
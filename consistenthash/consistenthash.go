@@ -67,3 +67,27 @@ func (m *Map) Get(key string) string {
 	// 通过 hashMap 映射得到真实的节点
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
+
+// GetN 从与所提供的键最接近的项开始，沿哈希环顺时针返回最多 n 个不同的真实节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	// 同一个真实节点的多个虚拟节点只取第一次出现的那个
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
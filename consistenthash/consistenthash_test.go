@@ -40,4 +40,40 @@ func TestHashing(t *testing.T) {
 		}
 	}
 
-}
\ No newline at end of file
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+
+	// 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+
+	testCases := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"2", 2, []string{"2", "4"}},
+		{"11", 3, []string{"2", "4", "6"}},
+		{"23", 2, []string{"4", "6"}},
+		{"27", 5, []string{"2", "4", "6"}},
+		{"27", 0, nil},
+	}
+
+	for _, tc := range testCases {
+		got := hash.GetN(tc.key, tc.n)
+		if len(got) != len(tc.want) {
+			t.Errorf("GetN(%s, %d) = %v, want %v", tc.key, tc.n, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("GetN(%s, %d) = %v, want %v", tc.key, tc.n, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package geecache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prefixPicker 把以 "r" 开头的键交给 peer，其余的键由本节点负责
+type prefixPicker struct{ peer ProtoGetter }
+
+func (p prefixPicker) PickPeer(key string) (ProtoGetter, bool) {
+	if strings.HasPrefix(key, "r") {
+		return p.peer, true
+	}
+	return nil, false
+}
+
+func (p prefixPicker) GetAll() []ProtoGetter { return []ProtoGetter{p.peer} }
+
+func TestDiskCache(t *testing.T) {
+	var loads int32
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString(fmt.Sprintf("%0100s", key), time.Time{})
+	})
+	peer := &fakePeer{url: "owner"}
+	g := newTestGroup(t, getter, WithCacheBytes(400), WithPeerPicker(prefixPicker{peer}),
+		WithDiskCache(t.TempDir(), 1<<20))
+	ctx := context.Background()
+	var s string
+
+	// 从 mainCache 淘汰的本地键写入磁盘，再次访问时从磁盘读取而不调用 Getter
+	for i := 0; i < 10; i++ {
+		if err := g.Get(ctx, fmt.Sprint("k", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.CacheStats(DiskCache).Items == 0 {
+		t.Fatal("no entries spilled to disk")
+	}
+	if err := g.Get(ctx, "k0", StringSink(&s)); err != nil || s != fmt.Sprintf("%0100s", "k0") {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if loads != 10 || g.Stats.DiskHits.Get() != 1 {
+		t.Fatalf("loads = %d, disk hits = %d", loads, g.Stats.DiskHits.Get())
+	}
+
+	// 远程键只进入 hotCache，淘汰后不写入磁盘
+	for i := 0; i < 20; i++ {
+		if err := g.Get(ctx, fmt.Sprint("r", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, ok := g.diskCache.Get("r0"); ok {
+		t.Fatal("remote key written to disk")
+	}
+}
+
+// 注销组时关闭磁盘缓存，删除磁盘上的分段文件
+func TestDiskCacheDeregister(t *testing.T) {
+	dir := t.TempDir()
+	var loads int32
+	g := NewGroupWithOptions(t.Name(), countingGetter(&loads), WithCacheBytes(20),
+		WithPeerPicker(fixedPicker{}), WithDiskCache(dir, 1<<20))
+	var s string
+	for i := 0; i < 10; i++ {
+		if err := g.Get(context.Background(), fmt.Sprint("k", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.CacheStats(DiskCache).Items == 0 {
+		t.Fatal("no entries spilled to disk")
+	}
+	DeregisterGroup(t.Name())
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if err != nil || len(files) != 0 {
+		t.Fatalf("files left after deregister: %v, %v", files, err)
+	}
+}
@@ -0,0 +1,480 @@
+// diskcache 基于本地磁盘的二级缓存
+//
+// 数据以追加写的方式写入分段文件（segment），内存中只保存索引。
+// 索引按 LRU 顺序维护，总字节数超出预算时淘汰最久未使用的条目。
+// 被淘汰或覆盖的记录在文件中成为“死数据”，由后台压缩协程回收。
+//
+// 索引不会持久化，因此磁盘上的数据只在进程生命周期内有效：
+// New 会清空目录中遗留的分段文件，Close 会删除它们。
+
+package diskcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodingCaius/geecache/lru"
+)
+
+const (
+	// 每条记录的头部：crc32(4) | 键长度(4) | 值长度(4) | 过期时间(8)
+	headerSize = 20
+
+	segmentPrefix = "segment-"
+	segmentSuffix = ".log"
+
+	defaultSegmentBytes    = 64 << 20
+	defaultCompactInterval = time.Minute
+	defaultCompactRatio    = 0.5
+)
+
+// Options 控制磁盘缓存的行为，零值字段会使用默认值
+type Options struct {
+	// SegmentBytes 是单个分段文件的最大字节数，超过后切换到新的分段
+	SegmentBytes int64
+
+	// CompactInterval 是后台压缩的执行间隔，负数表示不启动后台压缩
+	CompactInterval time.Duration
+
+	// CompactRatio 是触发压缩的存活数据比例，分段中存活字节数低于该比例时会被压缩
+	CompactRatio float64
+
+	// Now 用于判断条目是否过期，默认为 time.Now
+	Now lru.NowFunc
+
+	// OnCompactError 在后台压缩失败时调用，为 nil 时忽略错误
+	OnCompactError func(error)
+}
+
+// Stats 是磁盘缓存的统计信息
+type Stats struct {
+	Bytes     int64 // 存活的键和值的字节数总和
+	Items     int64 // 存活的条目数
+	Gets      int64 // Get 调用次数
+	Hits      int64 // 命中次数
+	Evictions int64 // 因超出预算被淘汰的条目数
+	Segments  int64 // 当前的分段文件数
+	DiskBytes int64 // 分段文件占用的总字节数，包括尚未回收的死数据
+}
+
+// segment 是一个追加写的分段文件
+type segment struct {
+	id   int
+	f    *os.File
+	size int64 // 文件的总字节数
+	live int64 // 仍被索引引用的记录字节数
+}
+
+// location 记录一个条目在磁盘上的位置
+type location struct {
+	seg  *segment
+	off  int64
+	size int64 // 整条记录的字节数
+	n    int64 // 键和值的字节数，用于预算统计
+}
+
+// Cache 是一个并发安全的磁盘缓存
+type Cache struct {
+	mu sync.Mutex
+
+	dir      string
+	maxBytes int64
+	opts     Options
+
+	index    *lru.Cache[string, *location]
+	segments map[int]*segment
+	active   *segment
+	nextID   int
+
+	// 记录存活的键和值的字节数，受 maxBytes 约束
+	nbytes int64
+
+	// evicting 为 true 时表示正在因预算淘汰条目，用于区分淘汰和主动删除
+	evicting bool
+
+	nget, nhit, nevict int64
+
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// New 在 dir 目录下创建一个磁盘缓存，maxBytes 是存活数据的字节预算
+func New(dir string, maxBytes int64, opts Options) (*Cache, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("diskcache: maxBytes must be positive")
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSegmentBytes
+	}
+	if opts.CompactInterval == 0 {
+		opts.CompactInterval = defaultCompactInterval
+	}
+	if opts.CompactRatio <= 0 || opts.CompactRatio > 1 {
+		opts.CompactRatio = defaultCompactRatio
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("diskcache: create dir: %w", err)
+	}
+	// 索引不持久化，遗留的分段文件已无法访问，直接清理
+	if err := removeSegments(dir); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		opts:     opts,
+		segments: make(map[int]*segment),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	c.index = &lru.Cache[string, *location]{
+		Now:       opts.Now,
+		OnEvicted: c.onEvicted,
+	}
+	if err := c.rotateLocked(); err != nil {
+		return nil, err
+	}
+
+	if opts.CompactInterval > 0 {
+		go c.compactLoop()
+	} else {
+		close(c.done)
+	}
+	return c, nil
+}
+
+// onEvicted 在索引条目被移除或覆盖时调用，更新字节统计
+func (c *Cache) onEvicted(key string, loc *location) {
+	c.nbytes -= loc.n
+	loc.seg.live -= loc.size
+	if c.evicting {
+		c.nevict++
+	}
+}
+
+// Put 将键值对追加写入磁盘，expire 为零值表示永不过期
+func (c *Cache) Put(key string, value []byte, expire time.Time) error {
+	n := int64(len(key) + len(value))
+	if n > c.maxBytes {
+		return nil
+	}
+	rec := encodeRecord(key, value, expire)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errors.New("diskcache: cache closed")
+	}
+
+	loc, err := c.appendLocked(rec)
+	if err != nil {
+		return err
+	}
+	loc.n = n
+	c.index.Add(key, loc, expire)
+	c.nbytes += n
+	loc.seg.live += loc.size
+
+	// 超出预算时淘汰最久未使用的条目
+	c.evicting = true
+	for c.nbytes > c.maxBytes {
+		c.index.RemoveOldest()
+	}
+	c.evicting = false
+	c.reclaimLocked()
+	return nil
+}
+
+// Get 从磁盘读取键对应的值及其过期时间
+func (c *Cache) Get(key string) (value []byte, expire time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nget++
+	if c.closed {
+		return
+	}
+	loc, hit := c.index.Get(key)
+	if !hit {
+		c.reclaimLocked()
+		return
+	}
+	value, expire, err := c.readLocked(loc, key)
+	if err != nil {
+		// 记录损坏时丢弃该条目，当作未命中处理
+		c.index.Remove(key)
+		c.reclaimLocked()
+		return nil, time.Time{}, false
+	}
+	c.nhit++
+	return value, expire, true
+}
+
+// Remove 从磁盘缓存中删除键
+func (c *Cache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.index.Remove(key)
+	c.reclaimLocked()
+}
+
+// RemovePrefix 删除所有以 prefix 开头的键，返回删除的条目数
+func (c *Cache) RemovePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0
+	}
+	n := 0
+	for _, key := range c.index.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			c.index.Remove(key)
+			n++
+		}
+	}
+	c.reclaimLocked()
+	return n
+}
+
+// Stats 返回磁盘缓存的统计信息
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Stats{
+		Bytes:     c.nbytes,
+		Items:     int64(c.index.Entries()),
+		Gets:      c.nget,
+		Hits:      c.nhit,
+		Evictions: c.nevict,
+		Segments:  int64(len(c.segments)),
+	}
+	for _, seg := range c.segments {
+		s.DiskBytes += seg.size
+	}
+	return s
+}
+
+// Close 停止后台压缩，关闭并删除所有分段文件
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.stop)
+	c.mu.Unlock()
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for id, seg := range c.segments {
+		if err := seg.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := os.Remove(seg.f.Name()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.segments, id)
+	}
+	c.index.Clear()
+	c.active = nil
+	return firstErr
+}
+
+// Compact 压缩存活数据比例低于 CompactRatio 的分段，将其中存活的记录搬到当前分段后删除旧文件
+func (c *Cache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	// 先清理已过期的条目，让它们的空间也能被回收
+	c.index.RemoveExpired()
+
+	var victims []*segment
+	for _, seg := range c.segments {
+		if seg == c.active || seg.size == 0 {
+			continue
+		}
+		if float64(seg.live) < float64(seg.size)*c.opts.CompactRatio {
+			victims = append(victims, seg)
+		}
+	}
+	if len(victims) == 0 {
+		c.reclaimLocked()
+		return nil
+	}
+
+	isVictim := make(map[*segment]bool, len(victims))
+	for _, seg := range victims {
+		isVictim[seg] = true
+	}
+	for _, key := range c.index.Keys() {
+		loc, ok := c.index.Peek(key)
+		if !ok || !isVictim[loc.seg] {
+			continue
+		}
+		buf := make([]byte, loc.size)
+		if _, err := loc.seg.f.ReadAt(buf, loc.off); err != nil {
+			c.index.Remove(key)
+			continue
+		}
+		newLoc, err := c.appendLocked(buf)
+		if err != nil {
+			return err
+		}
+		// 原地修改位置信息，不改变条目在 LRU 中的顺序
+		loc.seg.live -= loc.size
+		newLoc.seg.live += newLoc.size
+		loc.seg, loc.off = newLoc.seg, newLoc.off
+	}
+	c.reclaimLocked()
+	return nil
+}
+
+func (c *Cache) compactLoop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.CompactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := c.Compact(); err != nil && c.opts.OnCompactError != nil {
+				c.opts.OnCompactError(err)
+			}
+		}
+	}
+}
+
+// appendLocked 将一条编码好的记录追加到当前分段，必要时切换到新分段
+func (c *Cache) appendLocked(rec []byte) (*location, error) {
+	if c.active.size > 0 && c.active.size+int64(len(rec)) > c.opts.SegmentBytes {
+		if err := c.rotateLocked(); err != nil {
+			return nil, err
+		}
+	}
+	seg := c.active
+	if _, err := seg.f.WriteAt(rec, seg.size); err != nil {
+		return nil, fmt.Errorf("diskcache: write segment %d: %w", seg.id, err)
+	}
+	loc := &location{seg: seg, off: seg.size, size: int64(len(rec))}
+	seg.size += int64(len(rec))
+	return loc, nil
+}
+
+// rotateLocked 创建一个新的分段文件作为当前写入的分段
+func (c *Cache) rotateLocked() error {
+	id := c.nextID
+	c.nextID++
+	name := filepath.Join(c.dir, fmt.Sprintf("%s%06d%s", segmentPrefix, id, segmentSuffix))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("diskcache: create segment: %w", err)
+	}
+	seg := &segment{id: id, f: f}
+	c.segments[id] = seg
+	c.active = seg
+	return nil
+}
+
+// reclaimLocked 删除不再包含任何存活数据的非当前分段
+func (c *Cache) reclaimLocked() {
+	for id, seg := range c.segments {
+		if seg == c.active || seg.live > 0 {
+			continue
+		}
+		seg.f.Close()
+		os.Remove(seg.f.Name())
+		delete(c.segments, id)
+	}
+}
+
+// readLocked 读取并校验一条记录
+func (c *Cache) readLocked(loc *location, key string) ([]byte, time.Time, error) {
+	buf := make([]byte, loc.size)
+	if _, err := loc.seg.f.ReadAt(buf, loc.off); err != nil {
+		return nil, time.Time{}, err
+	}
+	k, v, expire, err := decodeRecord(buf)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if k != key {
+		return nil, time.Time{}, errors.New("diskcache: key mismatch")
+	}
+	return v, expire, nil
+}
+
+// encodeRecord 将键值对编码为一条带校验和的记录
+func encodeRecord(key string, value []byte, expire time.Time) []byte {
+	rec := make([]byte, headerSize+len(key)+len(value))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(value)))
+	var e int64
+	if !expire.IsZero() {
+		e = expire.UnixNano()
+	}
+	binary.LittleEndian.PutUint64(rec[12:], uint64(e))
+	copy(rec[headerSize:], key)
+	copy(rec[headerSize+len(key):], value)
+	binary.LittleEndian.PutUint32(rec[0:], crc32.ChecksumIEEE(rec[4:]))
+	return rec
+}
+
+// decodeRecord 解码并校验一条记录
+func decodeRecord(rec []byte) (key string, value []byte, expire time.Time, err error) {
+	if len(rec) < headerSize {
+		return "", nil, time.Time{}, errors.New("diskcache: short record")
+	}
+	if crc32.ChecksumIEEE(rec[4:]) != binary.LittleEndian.Uint32(rec[0:]) {
+		return "", nil, time.Time{}, errors.New("diskcache: checksum mismatch")
+	}
+	klen := int(binary.LittleEndian.Uint32(rec[4:]))
+	vlen := int(binary.LittleEndian.Uint32(rec[8:]))
+	if headerSize+klen+vlen != len(rec) {
+		return "", nil, time.Time{}, errors.New("diskcache: bad record length")
+	}
+	if e := int64(binary.LittleEndian.Uint64(rec[12:])); e != 0 {
+		expire = time.Unix(0, e)
+	}
+	key = string(rec[headerSize : headerSize+klen])
+	value = make([]byte, vlen)
+	copy(value, rec[headerSize+klen:])
+	return key, value, expire, nil
+}
+
+// removeSegments 删除目录中的所有分段文件
+func removeSegments(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("diskcache: read dir: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("diskcache: remove stale segment: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package diskcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	expire := time.Now().Add(time.Hour).Truncate(time.Nanosecond)
+	if err := c.Put("myKey", []byte("myValue"), expire); err != nil {
+		t.Fatal(err)
+	}
+	val, e, ok := c.Get("myKey")
+	if !ok {
+		t.Fatal("Get(myKey) missed; want hit")
+	}
+	if string(val) != "myValue" {
+		t.Fatalf("Get(myKey) = %q; want %q", val, "myValue")
+	}
+	if !e.Equal(expire) {
+		t.Fatalf("expire = %v; want %v", e, expire)
+	}
+
+	c.Remove("myKey")
+	if _, _, ok := c.Get("myKey"); ok {
+		t.Fatal("Get returned a removed entry")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Now()
+	c, err := New(t.TempDir(), 1<<20, Options{
+		CompactInterval: -1,
+		Now:             func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Put("myKey", []byte("myValue"), now.Add(time.Second))
+	now = now.Add(2 * time.Second)
+	if _, _, ok := c.Get("myKey"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if s := c.Stats(); s.Bytes != 0 {
+		t.Fatalf("Bytes = %d; want 0", s.Bytes)
+	}
+}
+
+func TestEvictOverBudget(t *testing.T) {
+	// 每个条目占 10 字节，预算只够保存 3 个
+	c, err := New(t.TempDir(), 30, Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		c.Put(fmt.Sprintf("key%d", i), []byte("value!"), time.Time{})
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, ok := c.Get(fmt.Sprintf("key%d", i)); ok {
+			t.Fatalf("key%d should have been evicted", i)
+		}
+	}
+	for i := 2; i < 5; i++ {
+		if _, _, ok := c.Get(fmt.Sprintf("key%d", i)); !ok {
+			t.Fatalf("key%d should still be cached", i)
+		}
+	}
+	if s := c.Stats(); s.Evictions != 2 || s.Bytes != 30 {
+		t.Fatalf("Evictions = %d, Bytes = %d; want 2, 30", s.Evictions, s.Bytes)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, Options{
+		SegmentBytes:    64,
+		CompactInterval: -1,
+		CompactRatio:    0.75,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 20; i++ {
+		c.Put(fmt.Sprintf("key%02d", i), []byte("value"), time.Time{})
+	}
+	// 删除大部分条目，让旧分段中只剩少量存活数据
+	for i := 0; i < 20; i++ {
+		if i%5 != 0 {
+			c.Remove(fmt.Sprintf("key%02d", i))
+		}
+	}
+	before := c.Stats()
+	if err := c.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := c.Stats()
+	if after.DiskBytes >= before.DiskBytes {
+		t.Fatalf("DiskBytes = %d after compaction; want less than %d", after.DiskBytes, before.DiskBytes)
+	}
+	for i := 0; i < 20; i += 5 {
+		key := fmt.Sprintf("key%02d", i)
+		if val, _, ok := c.Get(key); !ok || string(val) != "value" {
+			t.Fatalf("Get(%s) = %q, %v after compaction; want value, true", key, val, ok)
+		}
+	}
+}
+
+func TestRemovePrefix(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20, Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, key := range []string{"user:1:name", "user:1:age", "user:2:name"} {
+		c.Put(key, []byte("value"), time.Time{})
+	}
+	if n := c.RemovePrefix("user:1:"); n != 2 {
+		t.Fatalf("RemovePrefix removed %d entries; want 2", n)
+	}
+	if _, _, ok := c.Get("user:1:name"); ok {
+		t.Fatal("Get returned an entry removed by prefix")
+	}
+	if _, _, ok := c.Get("user:2:name"); !ok {
+		t.Fatal("RemovePrefix removed an entry outside the prefix")
+	}
+}
@@ -1,10 +1,21 @@
 
 // 定义了两个自定义的错误类型 ErrNotFound 和 ErrRemoteCall，这两个错误类型都实现了 Go 语言的 error 接口。
 // ErrNotFound 用于指示请求的值在当前节点上不可用，而 ErrRemoteCall 用于指示在远程获取值时发生了错误
+// PeerError 和 RemoveError 用于报告向多个节点发送请求时各节点的失败，ErrOverloaded 表示本地加载超出了限制
 
 
 package geecache
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
 
 // ErrNotFound 应该从 `GetterFunc` 的实现中返回，以指示请求的值不可用。
 // 当进行远程 HTTP 调用以从其他 groupcache 实例检索值时，返回此错误将向 groupcache 指示请求的值不可用，并且不应尝试在本地调用“GetterFunc”。
@@ -35,4 +46,105 @@ func (e *ErrRemoteCall) Error() string {
 func (e *ErrRemoteCall) Is(target error) bool {
 	_, ok := target.(*ErrRemoteCall)
 	return ok
-}
\ No newline at end of file
+}
+
+// PeerError 表示对某个节点的请求失败，Peer 是该节点的 GetURL()
+type PeerError struct {
+	Peer string
+	Err  error
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("peer %s: %v", e.Peer, e.Err)
+}
+
+func (e *PeerError) Unwrap() error {
+	return e.Err
+}
+
+
+// RemoveError 由 Group.Remove 返回，列出删除 Key 时失败的每个节点及其原因。
+// 可以通过 errors.Is 和 errors.As 检查其中任意一个节点的错误。
+type RemoveError struct {
+	Key   string
+	Peers []*PeerError
+}
+
+func (e *RemoveError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "remove key '%s' failed on %d peer(s)", e.Key, len(e.Peers))
+	for i, p := range e.Peers {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(p.Error())
+	}
+	return b.String()
+}
+
+func (e *RemoveError) Unwrap() []error {
+	errs := make([]error, len(e.Peers))
+	for i, p := range e.Peers {
+		errs[i] = p
+	}
+	return errs
+}
+
+// ErrOverloaded 表示本地加载因超出该组的并发或速率限制而被拒绝，Getter 没有被调用。
+// 在排队期间调用方的上下文结束时，Err 为上下文的错误。
+type ErrOverloaded struct {
+	Group  string
+	Reason string
+	Err    error
+}
+
+func (e *ErrOverloaded) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("group '%s' overloaded (%s): %v", e.Group, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("group '%s' overloaded (%s)", e.Group, e.Reason)
+}
+
+func (e *ErrOverloaded) Is(target error) bool {
+	_, ok := target.(*ErrOverloaded)
+	return ok
+}
+
+func (e *ErrOverloaded) Unwrap() error {
+	return e.Err
+}
+
+// rpcError 将处理请求时的错误转换为 gRPC 状态，使请求方能够区分 ErrNotFound 和所有者加载失败。
+// ErrNotFound 对应 codes.NotFound，上下文的错误保持原样，其余错误对应 codes.Internal。
+func rpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, &ErrNotFound{}) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// fromRPCError 将 gRPC 请求的错误还原为 ErrNotFound 或 ErrRemoteCall，
+// 其余错误（节点不可达、超时等）加上 msg 后包装返回。
+func fromRPCError(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return &ErrNotFound{Msg: status.Convert(err).Message()}
+	case codes.Internal:
+		return &ErrRemoteCall{Msg: status.Convert(err).Message()}
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,233 @@
+// 事件监听
+// Listener 接收组内条目的加载、命中、未命中、设置、删除和淘汰事件，用于在缓存之上实现审计和指标统计。
+// 监听函数在触发事件的协程中同步调用，调用时不持有任何缓存锁，因此可以安全地读取该组，
+// 但应尽快返回，以免拖慢触发事件的请求。
+
+package geecache
+
+import (
+	"time"
+)
+
+// EventSource 表示事件涉及的数据来自哪里
+type EventSource int
+
+const (
+	// SourceMain 表示 mainCache
+	SourceMain EventSource = iota + 1
+
+	// SourceHot 表示 hotCache
+	SourceHot
+
+	// SourceDisk 表示磁盘二级缓存
+	SourceDisk
+
+	// SourcePeer 表示远程节点
+	SourcePeer
+
+	// SourceGetter 表示本地的 Getter
+	SourceGetter
+)
+
+func (s EventSource) String() string {
+	switch s {
+	case SourceMain:
+		return "main"
+	case SourceHot:
+		return "hot"
+	case SourceDisk:
+		return "disk"
+	case SourcePeer:
+		return "peer"
+	case SourceGetter:
+		return "getter"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictReason 表示条目离开缓存的原因
+type EvictReason int
+
+const (
+	// EvictCapacity 表示缓存超出上限，条目作为最旧的条目被淘汰
+	EvictCapacity EvictReason = iota + 1
+
+	// EvictExpired 表示条目已过期（包括宽限期）
+	EvictExpired
+
+	// EvictExplicit 表示条目被 Remove、RemoveByTag、RemovePrefix 或 Purge 等操作显式删除
+	EvictExplicit
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictExplicit:
+		return "explicit"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 描述组内发生的一次事件，不同事件只填充与其相关的字段
+type Event struct {
+	// Key 是事件涉及的键
+	Key string
+
+	// Size 是值的字节数，开启压缩时为缓存中压缩后的大小
+	Size int
+
+	// Source 是数据的来源：OnHit 为命中的缓存，OnLoad 为远程节点或 Getter，
+	// OnSet 为写入的位置，OnEvict 为条目所在的缓存
+	Source EventSource
+
+	// Duration 是 OnLoad、OnSet 和 OnRemove 对应操作的耗时，批量加载时为整批的耗时
+	Duration time.Duration
+
+	// Reason 是 OnEvict 中条目离开缓存的原因
+	Reason EvictReason
+
+	// Err 是 OnLoad、OnSet 和 OnRemove 对应操作返回的错误
+	Err error
+}
+
+// Listener 是一组事件回调，为 nil 的回调会被忽略
+type Listener struct {
+	// OnLoad 在每次从远程节点或 Getter 加载后调用，加载失败时 Err 非 nil。
+	// 远程加载失败后在本地重新加载时，两次加载各触发一次
+	OnLoad func(Event)
+
+	// OnHit 在 Get 或 GetMany 命中 mainCache、hotCache 或磁盘缓存时调用
+	OnHit func(Event)
+
+	// OnMiss 在 Get 或 GetMany 未命中缓存时调用，包括由负缓存直接应答的情况
+	OnMiss func(Event)
+
+	// OnSet 在每次调用 Set 后调用
+	OnSet func(Event)
+
+	// OnRemove 在每次调用 Remove 后调用，各缓存中被删除的条目另外通过 OnEvict 报告
+	OnRemove func(Event)
+
+	// OnEvict 在条目离开 mainCache 或 hotCache 时调用，新值覆盖旧值不视为淘汰
+	OnEvict func(Event)
+}
+
+// eventKind 表示 Listener 中的一种回调
+type eventKind int
+
+const (
+	eventLoad eventKind = iota
+	eventHit
+	eventMiss
+	eventSet
+	eventRemove
+	eventEvict
+)
+
+// handler 返回 l 中处理 kind 事件的回调
+func (l *Listener) handler(kind eventKind) func(Event) {
+	switch kind {
+	case eventLoad:
+		return l.OnLoad
+	case eventHit:
+		return l.OnHit
+	case eventMiss:
+		return l.OnMiss
+	case eventSet:
+		return l.OnSet
+	case eventRemove:
+		return l.OnRemove
+	case eventEvict:
+		return l.OnEvict
+	default:
+		return nil
+	}
+}
+
+// AddListener 为该组添加一个监听器，可以在组开始处理请求后调用
+func (g *Group) AddListener(l Listener) {
+	g.listenMu.Lock()
+	defer g.listenMu.Unlock()
+	var listeners []Listener
+	if p := g.listeners.Load(); p != nil {
+		listeners = append(listeners, *p...)
+	}
+	listeners = append(listeners, l)
+	g.listeners.Store(&listeners)
+	// 有监听器之后缓存才开始记录被淘汰的条目
+	g.mainCache.trackEvictions()
+	g.hotCache.trackEvictions()
+}
+
+// WithListener 在创建组时添加一个监听器，等同于创建后调用 AddListener
+func WithListener(l Listener) GroupOption {
+	return func(g *Group) {
+		g.AddListener(l)
+	}
+}
+
+// emit 将事件分发给所有监听器，调用方不能持有任何缓存锁
+func (g *Group) emit(kind eventKind, ev Event) {
+	p := g.listeners.Load()
+	if p == nil {
+		return
+	}
+	for i := range *p {
+		if fn := (*p)[i].handler(kind); fn != nil {
+			fn(ev)
+		}
+	}
+}
+
+// flushEvictions 将缓存中记录的被淘汰条目分发给监听器。
+// 淘汰可能发生在持有 loadGroup 锁的临界区内，因此缓存只负责记录，
+// 由各操作在释放锁之后调用该方法统一分发。
+func (g *Group) flushEvictions() {
+	if g.listeners.Load() == nil {
+		return
+	}
+	for _, c := range []struct {
+		cache  *cache
+		source EventSource
+	}{
+		{&g.mainCache, SourceMain},
+		{&g.hotCache, SourceHot},
+	} {
+		for _, e := range c.cache.takeEvictions() {
+			g.emit(eventEvict, Event{
+				Key:    e.key,
+				Size:   e.value.Len(),
+				Source: c.source,
+				Reason: e.reason,
+			})
+		}
+	}
+}
+
+// evictedEntry 是缓存记录的一个被淘汰的条目
+type evictedEntry struct {
+	key    string
+	value  ByteView
+	reason EvictReason
+}
+
+// trackEvictions 开始记录被淘汰的条目，记录的条目通过 takeEvictions 取出
+func (c *cache) trackEvictions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.track = true
+}
+
+// takeEvictions 取出并清空已记录的被淘汰条目
+func (c *cache) takeEvictions() []evictedEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evicted := c.evicted
+	c.evicted = nil
+	return evicted
+}
@@ -0,0 +1,85 @@
+package geecache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder 以 "类型:键:来源" 的形式记录收到的事件，淘汰事件记录原因
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) listener() Listener {
+	rec := func(kind string) func(Event) {
+		return func(e Event) {
+			s := fmt.Sprintf("%s:%s:%s", kind, e.Key, e.Source)
+			if kind == "evict" {
+				s = fmt.Sprintf("%s:%s:%s", kind, e.Key, e.Reason)
+			}
+			r.mu.Lock()
+			r.events = append(r.events, s)
+			r.mu.Unlock()
+		}
+	}
+	return Listener{
+		OnLoad: rec("load"), OnHit: rec("hit"), OnMiss: rec("miss"),
+		OnSet: rec("set"), OnRemove: rec("remove"), OnEvict: rec("evict"),
+	}
+}
+
+func (r *eventRecorder) has(want ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, e := range r.events {
+		seen[e] = true
+	}
+	for _, w := range want {
+		if !seen[w] {
+			return fmt.Errorf("missing event %s in %s", w, strings.Join(r.events, ", "))
+		}
+	}
+	return nil
+}
+
+func TestEvents(t *testing.T) {
+	rec := &eventRecorder{}
+	var g *Group
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		return dest.SetString(strings.Repeat("x", 100), time.Time{})
+	})
+	peer := &fakePeer{url: "owner"}
+	g = newTestGroup(t, getter, WithCacheBytes(350), WithPeerPicker(prefixPicker{peer}), WithListener(rec.listener()))
+	// 监听函数调用时不持有缓存锁，可以重新进入该组
+	g.AddListener(Listener{OnEvict: func(e Event) {
+		g.Peek(e.Key)
+	}})
+	ctx := context.Background()
+	var s string
+
+	for _, k := range []string{"a", "a", "b", "c", "d", "remote", "remote"} {
+		if err := g.Get(ctx, k, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Set(ctx, "e", []byte("v"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Remove(ctx, "e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.has(
+		"miss:a:unknown", "load:a:getter", "hit:a:main",
+		"load:remote:peer", "hit:remote:hot",
+		"evict:a:capacity",
+		"set:e:main", "remove:e:unknown", "evict:e:explicit",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -24,12 +24,18 @@ package geecache
 import (
 	"context"
 	"errors"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/CodingCaius/geecache/diskcache"
 	pb "github.com/CodingCaius/geecache/geecachepb"
+	"github.com/CodingCaius/geecache/hotkey"
 	"github.com/CodingCaius/geecache/lru"
 	"github.com/CodingCaius/geecache/singleflight"
 	"github.com/sirupsen/logrus"
@@ -93,6 +99,17 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// allGroups 返回所有已注册的组
+func allGroups() []*Group {
+	mu.RLock()
+	defer mu.RUnlock()
+	all := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		all = append(all, g)
+	}
+	return all
+}
+
 // 用于创建一个协调的、具备组意识的 Getter 对象。
 // NewGroup 用于创建一个 Group 对象，该对象实现了缓存组的协同工作。
 // newGroup 函数接受四个参数，其中第四个参数是 PeerPicker 接口的实例，用于选择对等节点。在 NewGroup 中，此参数被设为 nil，表示没有指定对等节点选择器。
@@ -101,14 +118,32 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 }
 
 // 从全局的缓存组池中移除指定名称的缓存组
+// 开启了 write-behind 的组会停止后台写入协程，队列中剩余的操作在它退出前写入
 func DeregisterGroup(name string) {
 	mu.Lock() //获取全局的读写互斥锁
+	g := groups[name]
 	delete(groups, name)
 	mu.Unlock()
+	if g == nil {
+		return
+	}
+	if g.writeBehind != nil {
+		g.writeBehind.stop()
+	}
+	if g.busCancel != nil {
+		g.busCancel()
+	}
+	// 停止后台压缩并删除磁盘上的分段文件
+	if g.diskCache != nil {
+		if err := g.diskCache.Close(); err != nil {
+			g.logDiskError(err)
+		}
+	}
 }
 
 // 如果peers为nil，则通过sync.Once调用peerPicker来初始化它。
-func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) *Group {
+// opts 在组注册之前依次应用，钩子函数看到的是配置完成的组。
+func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker, opts ...GroupOption) *Group {
 	// 为了确保创建的缓存组具有有效的数据获取方式，不允许传入一个空的 getter
 	if getter == nil {
 		panic("nil Getter")
@@ -129,6 +164,16 @@ func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) *G
 		loadGroup:   &singleflight.Group{},
 		setGroup:    &singleflight.Group{},
 		removeGroup: &singleflight.Group{},
+		hotKeys:     hotkey.New(0, 0, time.Minute),
+	}
+	g.hotKeys.Now = func() time.Time { return NowFunc() }
+	g.setEvictionPolicy(FixedRatioEviction, defaultHotRatio)
+	for _, opt := range opts {
+		opt(g)
+	}
+	// 磁盘缓存的目录和日志依赖组名和日志记录器，在所有选项应用之后再打开
+	if g.diskDir != "" {
+		g.openDiskCache(g.diskDir, g.diskBytes)
 	}
 	// 如果存在注册的新组钩子函数（newGroupHook），则调用该函数，并将新创建的组作为参数传递给它。这允许在创建组时执行额外的自定义逻辑。
 	if fn := newGroupHook; fn != nil {
@@ -183,6 +228,7 @@ type Group struct {
 	peers PeerPicker
 
 	// 限制 mainCache 和 hotCache 大小总和
+	// 加入 MemoryPool 后会在运行时被调整，因此需要通过原子操作访问
 	cacheBytes int64
 
 	// 包含对于当前进程及其对等体而言是有权威的键的缓存。这个缓存包含一致性哈希到当前进程的对等体号码的键。
@@ -193,6 +239,96 @@ type Group struct {
 	// 谨慎使用此缓存，以最大化可全局存储的键/值对的总数。
 	hotCache cache
 
+	// negCache 缓存 Getter 返回 ErrNotFound 的键，使重复的未命中可以在本地直接应答。
+	// negTTL 为零时表示未启用，通过 WithNegativeCache 开启。
+	negCache cache
+	negTTL   time.Duration
+	negBytes int64
+
+	// diskCache 是可选的本地磁盘二级缓存，接收从 mainCache 中因容量不足而淘汰的条目。
+	// 为 nil 时表示未启用，通过 WithDiskCache 开启，DeregisterGroup 时关闭。
+	diskCache *diskcache.Cache
+	diskDir   string
+	diskBytes int64
+
+	// staleGrace 是条目过期后仍可被返回的宽限期，为零时表示不启用。
+	// 在宽限期内 Get 会立即返回旧值，并在后台通过 loadGroup 刷新。
+	staleGrace time.Duration
+
+	// refreshAheadRatio 是提前刷新的阈值，条目的存活时间超过其生命周期的该比例后，
+	// 再次被访问时会在后台重新加载。为零时表示不启用。
+	refreshAheadRatio float64
+
+	// refreshing 记录正在后台刷新的键，保证每个键同时只有一个刷新协程
+	refreshing sync.Map
+
+	// hotKeys 估计每个键在本节点上的访问频率，用于 hotCache 的准入判断，
+	// 作为所有者时也会通过 GetResponse.MinuteQps 报告给请求方
+	hotKeys *hotkey.Tracker
+
+	// split 决定缓存超出上限时从 mainCache 还是 hotCache 中淘汰，通过 WithEvictionPolicy 和 WithHotCacheRatio 配置
+	split splitTuner
+
+	// hotAdmitQPS 是远程键加入 hotCache 所需的最低访问频率（次/秒），为零时所有远程键都会加入
+	hotAdmitQPS float64
+
+	// defaultTTL 是 Getter 加载的值没有设置过期时间时使用的存活时间，为零时表示永不过期
+	defaultTTL time.Duration
+
+	// hotCacheDisabled 为 true 时不使用 hotCache
+	hotCacheDisabled bool
+
+	// loadTimeout 限制每次调用 Getter 的时长（包括在 limiter 中排队的时间），为零时表示不限制
+	loadTimeout time.Duration
+
+	// limiter 非 nil 时限制调用 Getter 的并发数和速率，通过 WithLoadLimits 配置
+	limiter *loadLimiter
+
+	// logger 是该组使用的日志记录器，为 nil 时使用全局的 logger
+	logger Logger
+
+	// removeConcurrency 限制 Remove 同时向其他节点发送的请求数，为零时使用默认值；
+	// removeMode 决定其他节点删除失败时 Remove 是否返回错误
+	removeConcurrency int
+	removeMode        RemoveMode
+
+	// breakerOpts 非 nil 时为每个远程节点开启熔断器，breakers 以节点的 URL 为键保存 *circuitBreaker；
+	// peerRetry 是远程调用的重试策略，peerFallback 决定远程节点不可用时是否在本地加载
+	breakerOpts  *BreakerOptions
+	breakers     sync.Map
+	peerRetry    RetryOptions
+	peerFallback PeerFallback
+
+	// replicas 是每个键的副本数，不大于 1 时不复制，通过 WithReplication 配置
+	replicas int
+
+	// hedge 非 nil 时，所有者超过对冲延迟仍未应答则发出对冲请求，通过 WithHedging 开启
+	hedge *hedger
+
+	// writeBehind 非 nil 时，写入数据源的操作先进入队列，通过 WithWriteBehind 开启。
+	// storeSeq 在每次写入数据源前加一，本地加载期间它发生变化时加载结果不写入缓存，需要通过原子操作访问
+	writeBehind *writeBehind
+	storeSeq    uint64
+
+	// listeners 是通过 AddListener 添加的监听器，写入时复制，读取时不加锁
+	listenMu  sync.Mutex
+	listeners atomic.Pointer[[]Listener]
+
+	// bus 非 nil 时，Remove 和 Set 通过它向其他节点广播失效消息，通过 WithInvalidationBus 配置。
+	// busOrigin 标识本节点发布的消息，busCancel 取消订阅，busSeq 是最近收到的消息序号，只在订阅协程中访问
+	bus       InvalidationBus
+	busOrigin string
+	busCancel context.CancelFunc
+	busSeq    uint64
+
+	// gen 是组当前的代数，每次 Purge 加一，需要通过原子操作访问
+	gen uint64
+
+	// compressor 非 nil 时，长度不小于 compressMin 的值在缓存和网络传输中以压缩形式保存，
+	// 通过 WithCompression 配置
+	compressor  Compressor
+	compressMin int
+
 	// loadGroup 确保每个键仅获取一次（本地或远程），无论并发调用者数量如何。
 	loadGroup flightGroup // 处理重复请求
 
@@ -213,6 +349,7 @@ type Group struct {
 // 即 singleflight的结构体满足的接口
 type flightGroup interface {
 	Do(key string, f func() (any, error)) (any, error)
+	DoMany(keys []string, f func(keys []string) ([]any, []error)) ([]any, []error)
 	Lock(fn func())
 }
 
@@ -247,6 +384,66 @@ type Stats struct {
 
 	// 记录的是该节点向其他节点发起的网络请求的总数
 	ServerRequests AtomicInt
+
+	// 记录在内存中未命中、但在磁盘二级缓存中命中的次数
+	DiskHits AtomicInt
+
+	// 记录返回了已过期但仍在宽限期内的旧值的次数
+	StaleHits AtomicInt
+
+	// 记录后台刷新旧值的次数，以及其中失败的次数
+	StaleRefreshes   AtomicInt
+	StaleRefreshErrs AtomicInt
+
+	// 记录从远程获取的值被加入和被拒绝加入 hotCache 的次数
+	HotCacheAdmits  AtomicInt
+	HotCacheRejects AtomicInt
+
+	// hotCache 在 mainCache 与 hotCache 总字节数中的目标占比，单位为千分之一
+	HotCacheShare AtomicInt
+
+	// 记录由负缓存直接应答 ErrNotFound 的次数
+	NegativeHits AtomicInt
+
+	// 记录提前刷新（refresh-ahead）触发、成功和失败的次数
+	RefreshesTriggered AtomicInt
+	RefreshesSucceeded AtomicInt
+	RefreshesFailed    AtomicInt
+
+	// 记录本节点切换到新代数（即整组被清除）的次数
+	Purges AtomicInt
+
+	// 记录从失效总线收到的消息序号不连续、因而清空 hotCache 的次数
+	InvalidationGaps AtomicInt
+
+	// 记录 Remove 向其他节点转发删除请求时失败的节点数
+	RemovePeerErrors AtomicInt
+
+	// 记录写入数据源成功的操作数、失败（write-behind 模式下为重试后仍失败）的操作数，以及 write-behind 的重试次数
+	StoreWrites       AtomicInt
+	StoreWriteErrs    AtomicInt
+	StoreWriteRetries AtomicInt
+
+	// 记录熔断器打开的次数、因熔断器打开而未发出的远程请求数，以及远程调用的重试次数
+	BreakerOpens   AtomicInt
+	BreakerRejects AtomicInt
+	PeerRetries    AtomicInt
+
+	// 记录发出的对冲请求数，以及其中先于所有者返回成功结果的次数
+	HedgesSent AtomicInt
+	HedgesWon  AtomicInt
+
+	// 记录调用 Getter 前排队等待的次数、因超出限制被拒绝的次数，以及累计的排队时间（纳秒）
+	LoadsQueued  AtomicInt
+	LoadsShed    AtomicInt
+	LoadWaitTime AtomicInt
+
+	// 记录副本从其他副本的缓存中取得值的次数、所选副本失败后由其余副本应答的次数，
+	// 以及向其余副本推送成功和失败的次数
+	ReplicaHits      AtomicInt
+	ReplicaFailovers AtomicInt
+	ReplicaPushes    AtomicInt
+	ReplicaPushErrs  AtomicInt
 }
 
 // Name returns the name of the group.
@@ -254,6 +451,129 @@ func (g *Group) Name() string {
 	return g.name
 }
 
+// isStale 判断缓存中的值是否已过期、正处于宽限期内
+func (g *Group) isStale(v ByteView) bool {
+	return g.staleGrace > 0 && !v.e.IsZero() && v.e.Before(NowFunc())
+}
+
+// revalidate 在后台重新加载 key，同一个键同时只会有一个刷新在进行
+func (g *Group) revalidate(key string) {
+	if _, running := g.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer g.refreshing.Delete(key)
+		defer g.flushEvictions()
+		g.Stats.StaleRefreshes.Add(1)
+		// 调用方可能已经返回，使用独立的上下文
+		var v ByteView
+		if _, _, err := g.load(context.Background(), key, ByteViewSink(&v)); err != nil {
+			g.Stats.StaleRefreshErrs.Add(1)
+			if logger := g.getLogger(); logger != nil {
+				logger.Warn().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"key":      key,
+						"category": "groupcache",
+					}).Printf("error refreshing stale key, keeping stale value")
+			}
+		}
+	}()
+}
+
+// shouldRefreshAhead 判断条目是否已经消耗了足够比例的生命周期，需要提前刷新
+func (g *Group) shouldRefreshAhead(v ByteView) bool {
+	if g.refreshAheadRatio <= 0 || v.e.IsZero() || v.t.IsZero() {
+		return false
+	}
+	lifetime := v.e.Sub(v.t)
+	if lifetime <= 0 {
+		return false
+	}
+	return float64(NowFunc().Sub(v.t)) >= float64(lifetime)*g.refreshAheadRatio
+}
+
+// refreshAhead 在后台跳过缓存重新获取 key，并通过 loadGroup 与同一键的其他加载去重
+func (g *Group) refreshAhead(key string) {
+	if _, running := g.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	g.Stats.RefreshesTriggered.Add(1)
+	go func() {
+		defer g.refreshing.Delete(key)
+		defer g.flushEvictions()
+		_, err := g.loadGroup.Do(key, func() (interface{}, error) {
+			var v ByteView
+			value, _, err := g.fetch(context.Background(), key, ByteViewSink(&v))
+			if err != nil {
+				return nil, err
+			}
+			return value, nil
+		})
+		if err != nil {
+			g.Stats.RefreshesFailed.Add(1)
+			if logger := g.getLogger(); logger != nil {
+				logger.Warn().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"key":      key,
+						"category": "groupcache",
+					}).Printf("error refreshing key ahead of expiry")
+			}
+			return
+		}
+		g.Stats.RefreshesSucceeded.Add(1)
+	}()
+}
+
+// KeyQPS 返回本节点上 key 最近大约一分钟内的访问频率估计（次/秒）
+func (g *Group) KeyQPS(key string) float64 {
+	return g.hotKeys.Rate(key)
+}
+
+// CacheBytes 返回 mainCache 和 hotCache 大小总和的当前上限
+func (g *Group) CacheBytes() int64 {
+	return atomic.LoadInt64(&g.cacheBytes)
+}
+
+// setCacheBytes 修改缓存大小上限，缩小时会立即淘汰超出的条目
+func (g *Group) setCacheBytes(n int64) {
+	old := atomic.SwapInt64(&g.cacheBytes, n)
+	if n >= old {
+		return
+	}
+	g.loadGroup.Lock(func() {
+		g.evict()
+	})
+	g.flushEvictions()
+}
+
+// openDiskCache 在 dir 下以组名命名的子目录中打开 WithDiskCache 配置的磁盘二级缓存，
+// 失败时记录日志，该组不使用磁盘缓存
+func (g *Group) openDiskCache(dir string, maxBytes int64) {
+	dc, err := diskcache.New(filepath.Join(dir, url.PathEscape(g.name)), maxBytes, diskcache.Options{
+		Now:            NowFunc,
+		OnCompactError: g.logDiskError,
+	})
+	if err != nil {
+		g.logDiskError(err)
+		return
+	}
+	g.diskCache = dc
+}
+
+// logDiskError 记录磁盘缓存的错误
+func (g *Group) logDiskError(err error) {
+	if logger := g.getLogger(); logger != nil {
+		logger.Error().
+			WithFields(map[string]interface{}{
+				"err":      err,
+				"group":    g.name,
+				"category": "groupcache",
+			}).Printf("disk cache error")
+	}
+}
+
 // 初始化缓存组的节点选择器，初始化用于选择对等节点的机制
 func (g *Group) initPeers() {
 	if g.peers == nil {
@@ -267,20 +587,29 @@ func (g *Group) initPeers() {
 // 在整个过程中，对缓存命中和未命中的情况进行了统计。
 func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
 	g.peersOnce.Do(g.initPeers)
+	// 查找和加载过程中被淘汰的条目在返回前分发给监听器
+	defer g.flushEvictions()
 	g.Stats.Gets.Add(1)
+	g.hotKeys.Add(key)
 	if dest == nil {
 		return errors.New("groupcache: nil dest Sink")
 	}
 	// 从缓存中查找数据
-	value, cacheHit := g.lookupCache(key)
+	value, src, cacheHit := g.lookupCache(key)
 
 	if cacheHit {
-		g.Stats.CacheHits.Add(1)
+		g.onCacheHit(key, value, src)
 		// 将缓存中的数据设置到目标 Sink 中
 		return setSinkView(dest, value)
 	}
 
 	// 处理缓存未命中的情况
+	g.emit(eventMiss, Event{Key: key})
+
+	// 最近确认过不存在的键，直接返回 ErrNotFound，不再回源
+	if err, ok := g.lookupNegative(key); ok {
+		return err
+	}
 
 	// 初始化一个标志，表示目标 Sink 是否已经被填充
 	destPopulated := false
@@ -297,8 +626,23 @@ func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
 	return setSinkView(dest, value)
 }
 
-// Set 设置键值对到缓存中
-func (g *Group) Set(ctx context.Context, key string, value []byte, expire time.Time, hotCache bool) error {
+// onCacheHit 记录一次命中 src 的缓存命中，并按需在后台刷新旧值或即将过期的值
+func (g *Group) onCacheHit(key string, value ByteView, src EventSource) {
+	g.Stats.CacheHits.Add(1)
+	g.emit(eventHit, Event{Key: key, Size: value.Len(), Source: src})
+	// 数据已过期但仍在宽限期内，直接返回旧值，同时在后台刷新
+	if g.isStale(value) {
+		g.Stats.StaleHits.Add(1)
+		g.revalidate(key)
+	} else if g.shouldRefreshAhead(value) {
+		// 热点键即将过期，提前在后台刷新，避免过期时的延迟尖刺
+		g.refreshAhead(key)
+	}
+}
+
+// Set 设置键值对到缓存中，tags 是附加到该条目上的标签，用于 RemoveByTag
+// 当前节点拥有该 key 且 Getter 实现了 Setter 时，值会先写入数据源，写入失败则不更新缓存。
+func (g *Group) Set(ctx context.Context, key string, value []byte, expire time.Time, hotCache bool, tags ...string) error {
 	// 初始化用于选择对等节点的机制
 	g.peersOnce.Do(g.initPeers)
 
@@ -306,76 +650,152 @@ func (g *Group) Set(ctx context.Context, key string, value []byte, expire time.T
 		return errors.New("empty Set() key not allowed")
 	}
 
+	// 键已经有值，之前缓存的不存在结果失效
+	g.removeNegative(key)
+
+	start := time.Now()
 	// 使用 g.setGroup.Do 方法确保对于相同的 key，只有一个请求在执行
-	_, err := g.setGroup.Do(key, func() (interface{}, error) {
+	src, err := g.setGroup.Do(key, func() (interface{}, error) {
 		// 如果远程对等体拥有该 key
 		owner, ok := g.peers.PickPeer(key)
 		if ok {
 			// 通过远程对等体设置 key 的值
-			if err := g.setFromPeer(ctx, owner, key, value, expire); err != nil {
-				return nil, err
+			if err := g.setFromPeer(ctx, owner, key, value, expire, tags); err != nil {
+				return SourcePeer, err
 			}
-			// 如果需要，将值更新到本地缓存中
-			if hotCache {
-				g.localSet(key, value, expire, &g.hotCache)
+			// 本节点是副本之一时写入 mainCache 并推送给其余副本，否则按需更新到本地热缓存中
+			if _, self := g.replicaOwners(key); self {
+				g.localSet(key, value, expire, tags, &g.mainCache)
+				g.replicate(key, ByteView{b: value, e: expire, tags: tags, gen: g.generation()}, owner)
+			} else if hotCache && !g.hotCacheDisabled {
+				g.localSet(key, value, expire, tags, &g.hotCache)
 			}
-			return nil, nil
+			// 通知其他节点丢弃 hotCache 中的旧值
+			return SourcePeer, g.publishInvalidation(ctx, InvalidateSet, key)
 		}
 
-		// 如果当前节点拥有该 key，则将值设置到本地缓存中
-		g.localSet(key, value, expire, &g.mainCache)
-		return nil, nil
+		// 如果当前节点拥有该 key，先写入数据源，再将值设置到本地缓存中
+		if err := g.setOwned(ctx, key, value, expire, tags); err != nil {
+			return SourceMain, err
+		}
+		return SourceMain, g.publishInvalidation(ctx, InvalidateSet, key)
 	})
+	ev := Event{Key: key, Size: len(value), Duration: time.Since(start), Err: err}
+	ev.Source, _ = src.(EventSource)
+	g.emit(eventSet, ev)
 	// 返回可能出现的错误
 	return err
 }
 
 // Remove 会从缓存中清除密钥，然后将删除请求转发给所有对等点。
+// 配置了失效总线时只向所有者发送请求，其余节点通过总线得知删除。
+// 失败时返回 *RemoveError，列出每个失败的节点及其原因；所有者删除失败时不会再向其他节点转发。
+// 使用 RemoveBestEffort 模式时，除所有者以外的节点失败只记录日志，不返回错误。
 func (g *Group) Remove(ctx context.Context, key string) error {
 	g.peersOnce.Do(g.initPeers)
 
+	start := time.Now()
 	_, err := g.removeGroup.Do(key, func() (interface{}, error) {
 		// 首先从 key 所属的对等体移除
 		owner, ok := g.peers.PickPeer(key)
 		if ok {
 			if err := g.removeFromPeer(ctx, owner, key); err != nil {
-				return nil, err
+				return nil, &RemoveError{Key: key, Peers: []*PeerError{{Peer: owner.GetURL(), Err: err}}}
 			}
+		} else if err := g.persist(ctx, WriteOp{Key: key, Delete: true}); err != nil {
+			// 当前节点拥有该 key 时先从数据源删除
+			return nil, err
 		}
 		// 然后从本地缓存中移除
 		g.localRemove(key)
 
-		// 异步地清除 key 在所有对等体的主缓存和热缓存中的值
-		wg := sync.WaitGroup{}
-		errs := make(chan error)
+		// 配置了失效总线时，其他节点通过总线得知删除，即使暂时不可达，重连后也会清除旧值
+		if g.bus != nil {
+			return nil, g.publishInvalidation(ctx, InvalidateRemove, key)
+		}
 
+		// 异步地清除 key 在其他对等体的主缓存和热缓存中的值
+		var peers []ProtoGetter
 		for _, peer := range g.peers.GetAll() {
 			// 避免重复从 key 所属的对等体删除
 			if peer == owner {
 				continue
 			}
-
-			wg.Add(1)
-			go func(peer ProtoGetter) {
-				errs <- g.removeFromPeer(ctx, peer, key)
-				wg.Done()
-			}(peer)
+			peers = append(peers, peer)
+		}
+		failed := g.removeFromPeers(ctx, peers, key)
+		if len(failed) == 0 {
+			return nil, nil
+		}
+		g.Stats.RemovePeerErrors.Add(int64(len(failed)))
+		if g.removeMode == RemoveBestEffort {
+			if logger := g.getLogger(); logger != nil {
+				for _, f := range failed {
+					logger.Warn().
+						WithFields(map[string]interface{}{
+							"err":      f.Err,
+							"key":      key,
+							"category": "groupcache",
+						}).Printf("error removing key from peer '%s'", f.Peer)
+				}
+			}
+			return nil, nil
 		}
+		return nil, &RemoveError{Key: key, Peers: failed}
+	})
+	g.emit(eventRemove, Event{Key: key, Duration: time.Since(start), Err: err})
+	return err
+}
 
-		go func() {
-			wg.Wait()
-			close(errs)
-		}()
+// removeFromPeers 并发地从 peers 中删除 key，同时进行的请求数不超过 removeConcurrency，
+// 返回按节点排序的失败列表。ctx 结束后不再发起新的请求，未发送请求的节点同样报告为失败。
+func (g *Group) removeFromPeers(ctx context.Context, peers []ProtoGetter, key string) []*PeerError {
+	limit := g.removeConcurrency
+	if limit <= 0 {
+		limit = defaultRemoveConcurrency
+	}
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
 
-		// TODO(thrawn01): 是否应该报告所有错误？每个对等体的上下文取消错误报告不太有意义。
-		var err error
-		for e := range errs {
-			err = e
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []*PeerError
+	)
+	fail := func(peer ProtoGetter, err error) {
+		mu.Lock()
+		failed = append(failed, &PeerError{Peer: peer.GetURL(), Err: err})
+		mu.Unlock()
+	}
+	sem := make(chan struct{}, limit)
+	for _, peer := range peers {
+		if ctx != nil && ctx.Err() != nil {
+			fail(peer, ctx.Err())
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-done:
+			fail(peer, ctx.Err())
+			continue
 		}
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := g.removeFromPeer(ctx, peer, key); err != nil {
+				fail(peer, err)
+			}
+		}(peer)
+	}
+	wg.Wait()
 
-		return nil, err
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Peer < failed[j].Peer
 	})
-	return err
+	return failed
 }
 
 // load 通过本地调用 getter 或将其发送到另一台机器来加载指定键的数据。
@@ -399,97 +819,154 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 		// 2: fn()
 
 		// 首先再次检查缓存（g.lookupCache(key)）。如果缓存命中，直接返回缓存中的值，不进行后续的加载操作。
-		if value, cacheHit := g.lookupCache(key); cacheHit {
+		// 处于宽限期的旧值不算命中，否则后台刷新会直接拿回旧值
+		if value, _, cacheHit := g.lookupCache(key); cacheHit && !g.isStale(value) {
 			g.Stats.CacheHits.Add(1)
 			return value, nil
 		}
 		// 如果缓存未命中，记录缓存未命中的统计信息，并尝试从远程对等体获取数据
 		g.Stats.LoadsDeduped.Add(1)
-		var value ByteView
-		var err error
-		if peer, ok := g.peers.PickPeer(key); ok {
-			// 为了测量从远程对等体获取数据所花费的时间
-			start := time.Now()
+		value, populated, err := g.fetch(ctx, key, dest)
+		if err != nil {
+			return nil, err
+		}
+		destPopulated = populated // only one caller of load gets this return value
+		return value, nil
+	})
 
-			// get value from peers
-			value, err = g.getFromPeer(ctx, peer, key)
+	if err == nil {
+		value = viewi.(ByteView)
+	}
+	return
+}
 
-			// 时间计算，单位转换为毫秒
-			duration := int64(time.Since(start)) / int64(time.Millisecond)
+// fetch 不经过缓存，直接从所有者节点或本地 getter 获取数据，并填充到对应的缓存中。
+// 调用方需要通过 loadGroup 保证同一个键同时只有一个 fetch 在进行。
+func (g *Group) fetch(ctx context.Context, key string, dest Sink) (value ByteView, destPopulated bool, err error) {
+	// 记录开始加载时的代数，加载期间发生的 Purge 会使加载结果在写入缓存后立即失效
+	gen := g.generation()
+	if peer, ok := g.pickOwner(key); ok {
+		// 为了测量从远程对等体获取数据所花费的时间
+		start := time.Now()
+
+		// get value from peers
+		var from ProtoGetter
+		value, from, err = g.getFromOwner(ctx, peer, key, gen)
+		// 结果来自本地的对冲请求时，统计信息和缓存已经由 hedgeLocally 处理
+		if from == nil {
+			return value, false, err
+		}
+		peer = from
+		if err == nil {
+			g.observePeerLoad(time.Since(start))
+		}
+		g.emit(eventLoad, Event{Key: key, Size: value.Len(), Source: SourcePeer, Duration: time.Since(start), Err: err})
 
-			// 这段代码的目的是记录从远程对等体获取数据的耗时，以便在统计信息中记录最慢的请求的持续时间。
-			// 在后续的代码中，将这个持续时间与先前记录的最慢持续时间进行比较，并更新统计信息，以便了解系统性能。
+		// 时间计算，单位转换为毫秒
+		duration := int64(time.Since(start)) / int64(time.Millisecond)
 
-			// 比较当前获取数据的持续时间（duration）与之前记录的最慢持续时间
-			if g.Stats.GetFromPeersLatencyLower.Get() < duration {
-				g.Stats.GetFromPeersLatencyLower.Store(duration)
-			}
+		// 这段代码的目的是记录从远程对等体获取数据的耗时，以便在统计信息中记录最慢的请求的持续时间。
+		// 在后续的代码中，将这个持续时间与先前记录的最慢持续时间进行比较，并更新统计信息，以便了解系统性能。
 
-			if err == nil {
-				g.Stats.PeerLoads.Add(1)
-				return value, nil
-			}
+		// 比较当前获取数据的持续时间（duration）与之前记录的最慢持续时间
+		if g.Stats.GetFromPeersLatencyLower.Get() < duration {
+			g.Stats.GetFromPeersLatencyLower.Store(duration)
+		}
 
-			// 如果错误是context.Canceled，说明上下文已取消，直接返回错误。
-			if errors.Is(err, context.Canceled) {
-				return nil, err
-			}
+		if err == nil {
+			g.Stats.PeerLoads.Add(1)
+			return value, false, nil
+		}
 
-			// 如果错误是&ErrNotFound{}，说明对等体上不存在该数据，也直接返回错误
-			if errors.Is(err, &ErrNotFound{}) {
-				return nil, err
-			}
+		// 如果错误是context.Canceled，说明上下文已取消，直接返回错误。
+		if errors.Is(err, context.Canceled) {
+			return ByteView{}, false, err
+		}
 
-			// 如果错误是&ErrRemoteCall{}，说明远程调用出错，同样直接返回错误。
-			if errors.Is(err, &ErrRemoteCall{}) {
-				return nil, err
-			}
+		// 如果错误是&ErrNotFound{}，说明对等体上不存在该数据，也直接返回错误
+		if errors.Is(err, &ErrNotFound{}) {
+			g.populateNegative(key, err)
+			return ByteView{}, false, err
+		}
 
-			// 如果存在日志记录器（logger != nil），记录错误信息和相关的键（key），然后增加远程加载错误的统计信息（g.Stats.PeerErrors.Add(1)）。
-			if logger != nil {
-				logger.Error().
-					WithFields(map[string]interface{}{
-						"err":      err,
-						"key":      key,
-						"category": "groupcache",
-					}).Printf("error retrieving key from peer '%s'", peer.GetURL())
-			}
+		// 如果错误是&ErrRemoteCall{}，说明远程调用出错，同样直接返回错误。
+		if errors.Is(err, &ErrRemoteCall{}) {
+			return ByteView{}, false, err
+		}
 
-			g.Stats.PeerErrors.Add(1)
-			// 如果上下文不为nil且上下文的错误不为nil，则说明上下文已不再有效，直接返回错误。
-			if ctx != nil && ctx.Err() != nil {
-				// Return here without attempting to get locally
-				// since the context is no longer valid
-				return nil, err
-			}
+		// 熔断器打开时请求没有发出，不再记录错误；其余情况下如果存在日志记录器（logger != nil），
+		// 记录错误信息和相关的键（key），然后增加远程加载错误的统计信息（g.Stats.PeerErrors.Add(1)）。
+		if logger := g.getLogger(); logger != nil && !errors.Is(err, ErrCircuitOpen) {
+			logger.Error().
+				WithFields(map[string]interface{}{
+					"err":      err,
+					"key":      key,
+					"category": "groupcache",
+				}).Printf("error retrieving key from peer '%s'", peer.GetURL())
 		}
 
-		value, err = g.getLocally(ctx, key, dest)
-		if err != nil {
-			g.Stats.LocalLoadErrs.Add(1)
-			return nil, err
+		g.Stats.PeerErrors.Add(1)
+		// 如果上下文不为nil且上下文的错误不为nil，则说明上下文已不再有效，直接返回错误。
+		if ctx != nil && ctx.Err() != nil {
+			// Return here without attempting to get locally
+			// since the context is no longer valid
+			return ByteView{}, false, err
 		}
-		g.Stats.LocalLoads.Add(1)
-		destPopulated = true // only one caller of load gets this return value
-		// 将获取到的数据写入主缓存（g.mainCache）
-		g.populateCache(key, value, &g.mainCache)
-		return value, nil
-	})
+		// 开启复制时其余副本可能还持有该键
+		if value, ok := g.getFromReplicas(ctx, key, gen, peer); ok {
+			return value, false, nil
+		}
+		// 配置为不在本地回源时，所有者不可用直接返回错误；本节点是副本之一时仍由本节点加载
+		if _, self := g.replicaOwners(key); g.peerFallback == PeerFallbackFail && !self {
+			return ByteView{}, false, err
+		}
+	}
+	// 本节点是副本之一时，先向其他副本查找，都没有缓存时才回源
+	if value, ok := g.getFromSiblings(ctx, key, gen); ok {
+		return value, false, nil
+	}
+	return g.fetchLocally(ctx, key, dest, gen)
+}
 
+// fetchLocally 通过本地的 getter 加载 key 并写入 mainCache，gen 是开始加载时组的代数
+func (g *Group) fetchLocally(ctx context.Context, key string, dest Sink, gen uint64) (value ByteView, destPopulated bool, err error) {
+	start := time.Now()
+	seq := atomic.LoadUint64(&g.storeSeq)
+	value, err = g.getLocally(ctx, key, dest)
+	g.emit(eventLoad, Event{Key: key, Size: value.Len(), Source: SourceGetter, Duration: time.Since(start), Err: err})
 	if err != nil {
-		value = viewi.(ByteView)
+		g.Stats.LocalLoadErrs.Add(1)
+		if errors.Is(err, &ErrNotFound{}) {
+			g.populateNegative(key, err)
+		}
+		return ByteView{}, false, err
 	}
-	return
+	g.Stats.LocalLoads.Add(1)
+	g.observeLocalLoad(time.Since(start))
+	value.gen = gen
+	// 将获取到的数据写入主缓存（g.mainCache）
+	g.populateLoaded(key, value, seq)
+	// 本节点是副本之一时，把加载的值推送给其余副本
+	if _, self := g.replicaOwners(key); self {
+		g.replicate(key, value, nil)
+	}
+	return value, true, nil
 }
 
 // 缓存未命中时，调用回调函数获取数据，并填充缓存
 func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView, error) {
-	err := g.getter.Get(ctx, key, dest)
+	ctx, cancel := g.loadContext(ctx)
+	defer cancel()
+	err := g.getFromStore(ctx, key, dest)
 	// 如果获取数据时发生错误，会返回一个空的 ByteView 和相应的错误
 	if err != nil {
 		return ByteView{}, err
 	}
-	return dest.view()
+	value, err := dest.view()
+	if err != nil {
+		return ByteView{}, err
+	}
+	return g.withDefaultTTL(value), nil
 
 	// 如果数据成功获取，它将获取到的字节数组 bytes 使用 cloneBytes 函数进行克隆，然后创建一个 ByteView 结构体，并将克隆后的字节数组赋值给 ByteView 的 b 字段
 	// 这一步之所以要复制字节数组而不是直接传递 bytes，是为了确保数据的不可变性和安全性
@@ -499,18 +976,66 @@ func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView
 	// return value, nil
 }
 
-// getFromPeer 从远程节点获取数据
-func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
+// getFromPeer 从远程节点获取数据，gen 是开始加载时组的代数
+func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string, gen uint64) (ByteView, error) {
 	req := &pb.GetRequest{
-		Group: g.name,
-		Key:   key,
+		Group:      g.name,
+		Key:        key,
+		Generation: gen,
 	}
 	res := &pb.GetResponse{}
 	// 使用远程节点的 ProtoGetter 接口调用 peer.Get 方法，将请求结构体 req 发送给远程节点
-	err := peer.Get(ctx, req, res)
+	err := g.callPeer(ctx, peer, func() error {
+		return peer.Get(ctx, req, res)
+	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return g.acceptPeerResponse(key, res, gen)
+}
+
+// acceptPeerResponse 解析远程节点返回的 key 的数据，并按需加入 hotCache。
+// gen 是开始加载时组的代数。
+func (g *Group) acceptPeerResponse(key string, res *pb.GetResponse, gen uint64) (ByteView, error) {
+	value, err := g.parsePeerResponse(res, gen)
 	if err != nil {
 		return ByteView{}, err
 	}
+
+	// 本节点是副本之一时，从排在前面的副本取得的值属于本节点，写入 mainCache
+	if _, self := g.replicaOwners(key); self {
+		g.populateCache(key, value, &g.mainCache)
+		return value, nil
+	}
+
+	// 只有足够热的键才会加入本地热缓存，避免偶发访问的键挤掉有用的条目
+	if g.admitHot(key, res.MinuteQps) {
+		g.Stats.HotCacheAdmits.Add(1)
+		// 将获取的数据加入本地热缓存，以防止频繁从远程节点获取。
+		// 对方使用与本组相同的算法压缩时，直接缓存压缩后的数据，省去一次重新压缩
+		cached := value
+		if g.compressor != nil && res.Encoding == g.compressor.Name() {
+			cached.b, cached.enc = res.Value, g.compressor
+		}
+		g.populateCache(key, cached, &g.hotCache)
+	} else {
+		g.Stats.HotCacheRejects.Add(1)
+	}
+	return value, nil
+}
+
+// parsePeerResponse 将远程节点返回的数据解析为 ByteView。
+// gen 是开始加载时组的代数，对方的代数更新时本节点随之切换，更旧时拒绝其数据。
+func (g *Group) parsePeerResponse(res *pb.GetResponse, gen uint64) (ByteView, error) {
+	// 不支持代数的节点返回零，此时无法判断，按当前代数处理
+	if res.Generation != 0 && res.Generation < gen {
+		return ByteView{}, errOlderGeneration
+	}
+	if res.Generation > gen {
+		g.adoptGeneration(res.Generation)
+		gen = res.Generation
+	}
+
 	// 解析获取的响应：从 pb.GetResponse 结构体中解析获取的响应。如果成功获取响应，将其解析为 ByteView 结构体，其中包含从远程节点获取的数据。
 	var expire time.Time
 	if res.Expire != 0 {
@@ -520,28 +1045,49 @@ func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (
 		}
 	}
 
-	value := ByteView{b: res.Value, e: expire}
+	b, err := decompress(res.Value, res.Encoding)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return ByteView{b: b, e: expire, tags: res.Tags, gen: gen}, nil
+}
 
-	// Always populate the hot cache
-	// 将获取的数据加入本地热缓存，以防止频繁从远程节点获取
-	g.populateCache(key, value, &g.hotCache)
-	return value, nil
+// admitHot 判断从远程获取的 key 是否应该加入 hotCache。
+// 访问频率取所有者节点报告的频率和本节点观测到的频率中的较大值。
+func (g *Group) admitHot(key string, ownerQPS float64) bool {
+	if g.hotCacheDisabled {
+		return false
+	}
+	if g.hotAdmitQPS <= 0 {
+		return true
+	}
+	qps := g.hotKeys.Rate(key)
+	if ownerQPS > qps {
+		qps = ownerQPS
+	}
+	return qps >= g.hotAdmitQPS
 }
 
 // setFromPeer 用于向远程节点设置数据
-func (g *Group) setFromPeer(ctx context.Context, peer ProtoGetter, k string, v []byte, e time.Time) error {
+func (g *Group) setFromPeer(ctx context.Context, peer ProtoGetter, k string, v []byte, e time.Time, tags []string) error {
 	// 如果指定了过期时间 e，将其转换为纳秒并存储在 expire 变量中。
 	var expire int64
 	if !e.IsZero() {
 		expire = e.UnixNano()
 	}
+	v, encoding := g.compress(v)
 	req := &pb.SetRequest{
-		Expire: expire,
-		Group:  g.name,
-		Key:    k,
-		Value:  v,
+		Expire:   expire,
+		Group:    g.name,
+		Key:      k,
+		Value:      v,
+		Encoding:   encoding,
+		Tags:       tags,
+		Generation: g.generation(),
 	}
-	return peer.Set(ctx, req)
+	return g.callPeer(ctx, peer, func() error {
+		return peer.Set(ctx, req)
+	})
 }
 
 // removeFromPeer 向远程节点发起删除数据的请求
@@ -550,22 +1096,84 @@ func (g *Group) removeFromPeer(ctx context.Context, peer ProtoGetter, key string
 		Group: g.name,
 		Key:   key,
 	}
-	return peer.Remove(ctx, req)
+	return g.callPeer(ctx, peer, func() error {
+		return peer.Remove(ctx, req)
+	})
+}
+
+// setOwned 在所有者节点上设置 key：先写入数据源，再写入 mainCache 并推送给其余副本
+func (g *Group) setOwned(ctx context.Context, key string, value []byte, expire time.Time, tags []string) error {
+	if err := g.persist(ctx, WriteOp{Key: key, Value: value, Expire: expire}); err != nil {
+		return err
+	}
+	g.localSet(key, value, expire, tags, &g.mainCache)
+	g.replicate(key, ByteView{b: value, e: expire, tags: tags, gen: g.generation()}, nil)
+	return nil
 }
 
-// lookupCache 用于在缓存中查找指定键 key 对应的数据
-func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
+// acceptSet 处理其他节点通过 setFromPeer 发来的设置请求，本节点是该键的所有者
+func (g *Group) acceptSet(ctx context.Context, in *pb.SetRequest) error {
+	if in.GetKey() == "" {
+		return errors.New("empty Set() key not allowed")
+	}
+	g.adoptGeneration(in.GetGeneration())
+	b, err := decompress(in.GetValue(), in.GetEncoding())
+	if err != nil {
+		return err
+	}
+	var expire time.Time
+	if in.GetExpire() != 0 {
+		expire = time.Unix(0, in.GetExpire())
+	}
+	g.removeNegative(in.GetKey())
+	return g.setOwned(ctx, in.GetKey(), b, expire, in.GetTags())
+}
+
+// acceptRemove 处理其他节点通过 removeFromPeer 发来的删除请求，本节点是所有者时同时从数据源删除
+func (g *Group) acceptRemove(ctx context.Context, key string) error {
+	g.peersOnce.Do(g.initPeers)
+	if _, ok := g.peers.PickPeer(key); !ok {
+		if err := g.persist(ctx, WriteOp{Key: key, Delete: true}); err != nil {
+			return err
+		}
+	}
+	g.localRemove(key)
+	return nil
+}
+
+// lookupCache 用于在缓存中查找指定键 key 对应的数据，src 表示命中的缓存
+func (g *Group) lookupCache(key string) (value ByteView, src EventSource, ok bool) {
 	// 检查是否设置了缓存的大小限制
 	// 如果缓存大小限制小于等于零，表示不使用缓存，直接返回零值。
-	if g.cacheBytes <= 0 {
+	if g.CacheBytes() <= 0 {
 		return
 	}
-	value, ok = g.mainCache.get(key)
-	if ok {
-		return
+	// 已被 Purge 清除的条目按未命中处理
+	which, src := &g.mainCache, SourceMain
+	value, ok = g.getLive(which, key)
+	if !ok {
+		which, src = &g.hotCache, SourceHot
+		value, ok = g.getLive(which, key)
 	}
-	value, ok = g.hotCache.get(key)
-	return
+	if !ok {
+		value, ok = g.lookupDisk(key)
+		return value, SourceDisk, ok
+	}
+	// 缓存中可能保存的是压缩后的数据，解压失败的条目按未命中处理并丢弃
+	value, err := decodeView(value)
+	if err != nil {
+		which.remove(key)
+		if logger := g.getLogger(); logger != nil {
+			logger.Error().
+				WithFields(map[string]interface{}{
+					"err":      err,
+					"key":      key,
+					"category": "groupcache",
+				}).Printf("error decoding cached value")
+		}
+		return ByteView{}, 0, false
+	}
+	return value, src, true
 
 	// 它首先检查 mainCache，
 	// 如果在主缓存中找到了数据，就返回该数据和 true，表示查找成功。
@@ -574,15 +1182,35 @@ func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
 	// 如果在两个缓存中都没有找到数据，就返回零值 ByteView{} 和 false。
 }
 
+// lookupDisk 在磁盘二级缓存中查找 key，命中后将数据提升回 mainCache
+func (g *Group) lookupDisk(key string) (value ByteView, ok bool) {
+	if g.diskCache == nil {
+		return
+	}
+	// 在读取之前记录代数，读取期间发生的 Purge 会使提升的条目立即失效
+	gen := g.generation()
+	b, expire, ok := g.diskCache.Get(key)
+	if !ok {
+		return
+	}
+	g.Stats.DiskHits.Add(1)
+	value = ByteView{b: b, e: expire, gen: gen}
+	g.diskCache.Remove(key)
+	g.populateCache(key, value, &g.mainCache)
+	return value, true
+}
+
 // localSet 在本地缓存中设置键值对，并且可以指定数据的过期时间
-func (g *Group) localSet(key string, value []byte, expire time.Time, cache *cache) {
-	if g.cacheBytes <= 0 {
+func (g *Group) localSet(key string, value []byte, expire time.Time, tags []string, cache *cache) {
+	if g.CacheBytes() <= 0 {
 		return
 	}
 
 	bv := ByteView{
-		b: value,
-		e: expire,
+		b:    value,
+		e:    expire,
+		tags: tags,
+		gen:  g.generation(),
 	}
 
 	// 确保没有请求正在执行,通过对 loadGroup 进行加锁来实现。
@@ -590,7 +1218,9 @@ func (g *Group) localSet(key string, value []byte, expire time.Time, cache *cach
 		// 在加锁的环境中执行下面的操作
 		// 在加锁的环境中，调用 populateCache 方法，将键为 key、值为 bv 的数据添加到指定的缓存 cache 中
 		g.populateCache(key, bv, cache)
+		g.removeNegative(key)
 	})
+	g.flushEvictions()
 
 	//通过对 loadGroup 的加锁，确保在设置缓存时没有其他请求在飞行，以避免并发冲突。这种机制可以确保对缓存的并发访问是安全的。
 }
@@ -598,7 +1228,7 @@ func (g *Group) localSet(key string, value []byte, expire time.Time, cache *cach
 // localRemove 在本地缓存中移除指定键的数据
 func (g *Group) localRemove(key string) {
 	// Clear key from our local cache
-	if g.cacheBytes <= 0 {
+	if g.CacheBytes() <= 0 {
 		return
 	}
 
@@ -607,36 +1237,57 @@ func (g *Group) localRemove(key string) {
 		// 在加锁的环境中，分别从热缓存 hotCache 和主缓存 mainCache 中移除指定键 key 的数据。
 		g.hotCache.remove(key)
 		g.mainCache.remove(key)
+		g.removeNegative(key)
+		if g.diskCache != nil {
+			g.diskCache.Remove(key)
+		}
 	})
+	g.flushEvictions()
 }
 
 // populateCache 向指定的缓存（cache）中添加键值对，并在添加后检查缓存是否超出预定的大小，如果超出，则进行适当的淘汰策略
 func (g *Group) populateCache(key string, value ByteView, cache *cache) {
 	// 首先，检查是否设置了缓存的大小限制（g.cacheBytes <= 0）。如果缓存大小限制小于等于零，表示不使用缓存，直接返回
-	if g.cacheBytes <= 0 {
+	if g.CacheBytes() <= 0 {
 		return
 	}
-	// 向指定的缓存（cache）中添加键值对
-	cache.add(key, value)
+	// 向指定的缓存（cache）中添加键值对，开启压缩时保存压缩后的数据，cacheBytes 按压缩后的大小计算
+	cache.add(key, g.encodeView(value))
 
 	// Evict items from cache(s) if necessary.
+	g.evict()
+}
+
+// evict 从 mainCache 和 hotCache 中淘汰最旧的条目，直到两者的大小总和不超过 cacheBytes
+func (g *Group) evict() {
 	for {
 		mainBytes := g.mainCache.bytes()
 		hotBytes := g.hotCache.bytes()
-		if mainBytes+hotBytes <= g.cacheBytes {
+		if mainBytes+hotBytes <= g.CacheBytes() {
 			return
 		}
 
-		// TODO(bradfitz): this is good-enough-for-now logic.
-		// It should be something based on measurements and/or
-		// respecting the costs of different resources.
-		victim := &g.mainCache
-		// 当前实现中，优先选择要淘汰的缓存是 mainCache 还是 hotCache，取决于它们的字节数比例
-		if hotBytes > mainBytes/8 {
-			victim = &g.hotCache
-		}
+		// 由淘汰策略决定从 mainCache 还是 hotCache 中淘汰
+		victim := g.chooseVictim(mainBytes, hotBytes)
 		// 从选择的缓存中移除最老的键值对，以释放空间
-		victim.removeOldest()
+		k, v, ok := victim.removeOldest()
+		// 从 mainCache 淘汰的数据写入磁盘二级缓存，避免下次未命中时直接回源。
+		// 磁盘缓存不保存标签，带标签的条目不写入，以免逃过 RemoveByTag；
+		// 磁盘缓存也不保存代数，已被 Purge 清除的条目直接丢弃，否则读回时会被当作新数据
+		if ok && victim == &g.mainCache && g.diskCache != nil && len(v.tags) == 0 && !g.purged(v) {
+			v, err := decodeView(v)
+			if err == nil {
+				err = g.diskCache.Put(k, v.ByteSlice(), v.Expire())
+			}
+			if logger := g.getLogger(); err != nil && logger != nil {
+				logger.Error().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"key":      k,
+						"category": "groupcache",
+					}).Printf("error writing evicted key to disk cache")
+			}
+		}
 	}
 }
 
@@ -649,6 +1300,12 @@ const (
 
 	// HotCache 是看起来足够流行以复制到此节点的项目的缓存，即使它不是所有者。
 	HotCache
+
+	// DiskCache 是保存从 MainCache 中淘汰的项目的磁盘二级缓存。
+	DiskCache
+
+	// NegativeCache 是保存最近确认不存在的键的负缓存。
+	NegativeCache
 )
 
 // CacheStats 根据指定的缓存类型返回相应缓存的统计信息
@@ -658,11 +1315,82 @@ func (g *Group) CacheStats(which CacheType) CacheStats {
 		return g.mainCache.stats()
 	case HotCache:
 		return g.hotCache.stats()
+	case NegativeCache:
+		return g.negCache.stats()
+	case DiskCache:
+		if g.diskCache == nil {
+			return CacheStats{}
+		}
+		s := g.diskCache.Stats()
+		return CacheStats{
+			Bytes:     s.Bytes,
+			Items:     s.Items,
+			Gets:      s.Gets,
+			Hits:      s.Hits,
+			Evictions: s.Evictions,
+		}
 	default:
 		return CacheStats{}
 	}
 }
 
+// Range 按从旧到新的顺序遍历指定缓存中所有未过期的条目，fn 返回 false 时停止遍历。
+// 遍历不会更新条目的最近使用状态，也不会计入缓存的统计信息。
+// 开启宽限期时，也会遍历到已过期但仍在宽限期内的条目，可通过 v.Expire() 区分。
+// 仅支持 MainCache 和 HotCache。
+func (g *Group) Range(which CacheType, fn func(key string, v ByteView) bool) {
+	var c *cache
+	switch which {
+	case MainCache:
+		c = &g.mainCache
+	case HotCache:
+		c = &g.hotCache
+	default:
+		return
+	}
+	// 已被清除和解压失败的条目会被跳过
+	c.each(func(key string, v ByteView) bool {
+		if g.purged(v) {
+			return true
+		}
+		v, err := decodeView(v)
+		if err != nil {
+			return true
+		}
+		return fn(key, v)
+	})
+}
+
+// RangePrefix 与 Range 相同，但只遍历以 prefix 开头的键
+func (g *Group) RangePrefix(which CacheType, prefix string, fn func(key string, v ByteView) bool) {
+	g.Range(which, func(key string, v ByteView) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return true
+		}
+		return fn(key, v)
+	})
+}
+
+// Peek 在 mainCache 和 hotCache 中查找 key，不会更新条目的最近使用状态，
+// 不计入统计信息，未命中时也不会触发加载。
+func (g *Group) Peek(key string) (ByteView, bool) {
+	v, ok := g.mainCache.peek(key)
+	if !ok || g.purged(v) {
+		v, ok = g.hotCache.peek(key)
+	}
+	if ok && g.purged(v) {
+		ok = false
+	}
+	if !ok {
+		return ByteView{}, false
+	}
+	v, err := decodeView(v)
+	if err != nil {
+		return ByteView{}, false
+	}
+	return v, true
+}
+
 // NowFunc 返回当前时间，LRU 使用该时间来确定该值是否已过期。 这可以通过测试来覆盖，以确保项目在过期时被驱逐。
 // NowFunc 被初始化为 time.Now，即获取当前系统时间的函数
 var NowFunc lru.NowFunc = time.Now
@@ -676,7 +1404,7 @@ type cache struct {
 	// 记录所有键和值的大小总和
 	nbytes int64
 
-	lru *lru.Cache
+	lru *lru.Cache[string, ByteView]
 
 	// nhit int64: 记录缓存命中的次数
 	// nget int64: 记录缓存访问的总次数
@@ -685,6 +1413,15 @@ type cache struct {
 	// 记录缓存的驱逐（eviction）次数
 	nevict int64
 
+	// 条目过期后在 lru 中继续保留的宽限期，由 WithStaleGrace 设置
+	grace time.Duration
+
+	// track 为 true 时，被淘汰的条目会连同原因记录到 evicted 中，等待 Group.flushEvictions 分发。
+	// reason 是当前 lru 操作淘汰条目的原因，为零时（例如新值覆盖旧值）不记录
+	track   bool
+	evicted []evictedEntry
+	reason  EvictReason
+
 	// cache 中包含缓存命中的统计信息是为了在缓存层面更方便地跟踪和记录这些信息
 	// 这样在maincache和hotcache层就也有了统计信息，更方便更新和操作
 }
@@ -710,18 +1447,27 @@ func (c *cache) add(key string, value ByteView) {
 	// 检查缓存是否为空。如果为空，说明这是第一次添加数据，需要初始化缓存
 	if c.lru == nil {
 		// 创建一个新的lru.Cache实例
-		c.lru = &lru.Cache{
+		c.lru = &lru.Cache[string, ByteView]{
 			Now: NowFunc, // 获取当前时间
 			// 定义OnEvicted回调函数，该函数在缓存中的数据被逐出时执行，用于更新统计信息
-			OnEvicted: func(key lru.Key, value interface{}) {
-				val := value.(ByteView)
-				c.nbytes -= int64(len(key.(string))) + int64(val.Len())
-				c.nevict++
+			OnEvicted: func(key string, value ByteView) {
+				c.nbytes -= int64(len(key)) + int64(value.Len())
+				if c.track && c.reason != 0 {
+					c.evicted = append(c.evicted, evictedEntry{key: key, value: value, reason: c.reason})
+				}
 			},
 		}
 	}
 	// 调用lru包中的Add方法将键值对添加到缓存中。同时，传递了过期时间（value.Expire()），用于在逐出时检查是否过期
-	c.lru.Add(key, value, value.Expire())
+	// 开启宽限期时，lru 中的过期时间会顺延，真正的过期时间仍保存在 ByteView 中
+	// 记录加入缓存的时间，用于计算条目已消耗的生命周期
+	value.t = NowFunc()
+	expire := value.Expire()
+	if !expire.IsZero() && c.grace > 0 {
+		expire = expire.Add(c.grace)
+	}
+	c.reason = 0
+	c.lru.Add(key, value, expire)
 	c.nbytes += int64(len(key)) + int64(value.Len())
 }
 
@@ -733,13 +1479,24 @@ func (c *cache) get(key string) (value ByteView, ok bool) {
 	if c.lru == nil {
 		return
 	}
-	vi, ok := c.lru.Get(key)
+	// lru.Get 会顺便删除已过期的条目
+	c.reason = EvictExpired
+	value, ok = c.lru.Get(key)
 	if !ok {
 		return
 	}
 	c.nhit++
-	// 将值转换为ByteView类型并返回
-	return vi.(ByteView), true
+	return value, true
+}
+
+// peek 获取键对应的值，但不更新最近使用状态和命中统计
+func (c *cache) peek(key string) (value ByteView, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.lru == nil {
+		return
+	}
+	return c.lru.Peek(key)
 }
 
 // remove 从缓存中移除指定键的条目
@@ -749,14 +1506,50 @@ func (c *cache) remove(key string) {
 	if c.lru == nil {
 		return
 	}
+	c.reason = EvictExplicit
 	c.lru.Remove(key)
 }
 
-func (c *cache) removeOldest() {
+// removeOldest 移除最旧的未过期条目，并返回被移除的键值对
+func (c *cache) removeOldest() (key string, value ByteView, ok bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru != nil {
-		c.lru.RemoveOldest()
+	if c.lru == nil {
+		return
+	}
+	// GetOldest 会顺便删除排在前面的已过期条目
+	c.reason = EvictExpired
+	key, value, ok = c.lru.GetOldest()
+	if ok {
+		c.reason = EvictCapacity
+		c.lru.Remove(key)
+		// 只有因空间不足而移除的条目才计入驱逐次数
+		c.nevict++
+	}
+	return
+}
+
+// each 对缓存中每个未过期的条目调用 fn，fn 返回 false 时停止遍历。
+// 条目在持有锁时被复制出来，fn 在锁外执行，因此可以安全地访问缓存。
+// 遍历顺序为从旧到新，且不会更新条目的最近使用状态。
+func (c *cache) each(fn func(key string, value ByteView) bool) {
+	c.mu.RLock()
+	if c.lru == nil {
+		c.mu.RUnlock()
+		return
+	}
+	keys := c.lru.Keys()
+	values := make([]ByteView, 0, len(keys))
+	for _, key := range keys {
+		v, _ := c.lru.Peek(key)
+		values = append(values, v)
+	}
+	c.mu.RUnlock()
+
+	for i, key := range keys {
+		if !fn(key, values[i]) {
+			return
+		}
 	}
 }
 
@@ -767,6 +1560,13 @@ func (c *cache) bytes() int64 {
 	return c.nbytes
 }
 
+// hits 获取缓存的累计命中次数
+func (c *cache) hits() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nhit
+}
+
 // items 获取缓存中的键值对数量
 func (c *cache) items() int64 {
 	c.mu.RLock()
@@ -779,7 +1579,7 @@ func (c *cache) itemsLocked() int64 {
 	if c.lru == nil {
 		return 0
 	}
-	return int64(c.lru.Len())
+	return int64(c.lru.Entries())
 }
 
 // int64 类型的别名，用于在并发环境下安全地进行原子操作
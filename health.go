@@ -0,0 +1,225 @@
+// 节点健康检查
+// 节点宕机后，直到它在 etcd 中的租约过期、成员发生变化之前，PickPeer 都会继续选择它。
+// server 记录每个远程节点最近的请求结果，并定期发送探测请求：连续失败达到阈值的节点被暂时摘除，
+// 它负责的键改由哈希环上的下一个健康节点负责；摘除后连续探测成功达到次数时恢复。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HealthOptions 是节点健康检查的配置，为零的字段使用默认值
+type HealthOptions struct {
+	// FailureThreshold 是摘除节点所需的连续失败次数（包括请求和探测），默认为 3
+	FailureThreshold int
+
+	// RecoveryProbes 是恢复被摘除的节点所需的连续探测成功次数，默认为 2
+	RecoveryProbes int
+
+	// ProbeInterval 是两轮探测之间的间隔，默认为 1s
+	ProbeInterval time.Duration
+
+	// ProbeTimeout 是每次探测的超时时间，默认为 500ms
+	ProbeTimeout time.Duration
+}
+
+// healthTracker 记录各个远程节点的健康状态，为 nil 时认为所有节点都健康
+type healthTracker struct {
+	opts HealthOptions
+
+	mu    sync.Mutex
+	peers map[string]*peerHealth
+}
+
+// peerHealth 是一个远程节点的健康状态
+type peerHealth struct {
+	// failures 是连续失败的次数，successes 是摘除后连续探测成功的次数
+	failures  int
+	successes int
+	down      bool
+}
+
+func newHealthTracker(opts HealthOptions) *healthTracker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+	if opts.RecoveryProbes <= 0 {
+		opts.RecoveryProbes = 2
+	}
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = time.Second
+	}
+	if opts.ProbeTimeout <= 0 {
+		opts.ProbeTimeout = 500 * time.Millisecond
+	}
+	return &healthTracker{opts: opts, peers: make(map[string]*peerHealth)}
+}
+
+// healthy 判断节点 addr 是否健康
+func (h *healthTracker) healthy(addr string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.peers[addr]
+	return !ok || !p.down
+}
+
+// observe 记录对节点 addr 的一次请求或探测的结果，probe 表示是否是探测。
+// 被摘除的节点只根据探测结果恢复，返回节点的健康状态是否因此改变。
+func (h *healthTracker) observe(addr string, ok, probe bool) (changed bool) {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.peers[addr]
+	if p == nil {
+		p = &peerHealth{}
+		h.peers[addr] = p
+	}
+	if !ok {
+		p.successes = 0
+		p.failures++
+		if !p.down && p.failures >= h.opts.FailureThreshold {
+			p.down = true
+			return true
+		}
+		return false
+	}
+	p.failures = 0
+	if !p.down || !probe {
+		return false
+	}
+	p.successes++
+	if p.successes >= h.opts.RecoveryProbes {
+		p.down, p.successes = false, 0
+		return true
+	}
+	return false
+}
+
+// retain 只保留 addrs 中节点的状态，成员变化后调用
+func (h *healthTracker) retain(addrs []string) {
+	if h == nil {
+		return
+	}
+	keep := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		keep[addr] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for addr := range h.peers {
+		if !keep[addr] {
+			delete(h.peers, addr)
+		}
+	}
+}
+
+// peerDown 判断一次 gRPC 请求的错误是否说明节点不可用，节点正常应答的业务错误不算
+func peerDown(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// SetHealthOptions 设置节点健康检查的参数，必须在 Start 之前调用
+func (s *server) SetHealthOptions(opts HealthOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health = newHealthTracker(opts)
+	for _, c := range s.clients {
+		c.health = s.health
+	}
+}
+
+// PeerHealth 返回各个远程节点是否健康，被摘除的节点为 false
+func (s *server) PeerHealth() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	health := make(map[string]bool, len(s.clients))
+	for addr := range s.clients {
+		if addr != s.addr {
+			health[addr] = s.health.healthy(addr)
+		}
+	}
+	return health
+}
+
+// healthyOwners 沿哈希环返回 key 的前 n 个健康节点，本节点总是被认为健康。调用方需要持有 s.mu
+func (s *server) healthyOwners(key string, n int) []string {
+	var owners []string
+	for _, addr := range s.consHash.GetN(key, len(s.clients)) {
+		if addr == s.addr || s.health.healthy(addr) {
+			owners = append(owners, addr)
+			if len(owners) == n {
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// probePeers 每隔 ProbeInterval 探测一次所有远程节点，直到 stop 被关闭
+func (s *server) probePeers(stop <-chan struct{}) {
+	s.mu.Lock()
+	h := s.health
+	s.mu.Unlock()
+	ticker := time.NewTicker(h.opts.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		clients := make(map[string]*client, len(s.clients))
+		for addr, c := range s.clients {
+			if addr != s.addr {
+				clients[addr] = c
+			}
+		}
+		s.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for addr, c := range clients {
+			wg.Add(1)
+			go func(addr string, c *client) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), h.opts.ProbeTimeout)
+				defer cancel()
+				// 与请求一样只有节点不可达才算失败，本节点连不上 etcd 等错误不应摘除所有节点
+				err := c.Ping(ctx, &pb.PingRequest{})
+				if h.observe(addr, !peerDown(err), true) {
+					if h.healthy(addr) {
+						log.Printf("[cache %s] peer %s recovered", s.addr, addr)
+					} else {
+						log.Printf("[cache %s] peer %s marked unhealthy: %v", s.addr, addr, err)
+					}
+				}
+			}(addr, c)
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,126 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthTracker(t *testing.T) {
+	h := newHealthTracker(HealthOptions{FailureThreshold: 2, RecoveryProbes: 2})
+	if h.observe("a", false, false) || !h.healthy("a") {
+		t.Fatal("one failure should not mark the peer down")
+	}
+	if !h.observe("a", false, true) || h.healthy("a") {
+		t.Fatal("two failures should mark the peer down")
+	}
+	// 请求成功不能恢复被摘除的节点，只有连续的探测成功可以
+	if h.observe("a", true, false) || h.healthy("a") {
+		t.Fatal("a request success must not restore the peer")
+	}
+	if h.observe("a", true, true) || h.healthy("a") {
+		t.Fatal("one probe success should not restore the peer")
+	}
+	if !h.observe("a", true, true) || !h.healthy("a") {
+		t.Fatal("two probe successes should restore the peer")
+	}
+
+	h.observe("b", false, true)
+	h.observe("b", false, true)
+	h.retain([]string{"a"})
+	if !h.healthy("b") {
+		t.Fatal("retain should forget removed peers")
+	}
+}
+
+func TestPeerDown(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		down bool
+	}{
+		{nil, false},
+		{status.Error(codes.Unavailable, "connection refused"), true},
+		{fmt.Errorf("could not ping: %w", status.Error(codes.DeadlineExceeded, "timeout")), true},
+		{context.DeadlineExceeded, true},
+		{status.Error(codes.NotFound, "no such key"), false},
+		{&ErrRemoteCall{Msg: "db down"}, false},
+		// 本节点连不上 etcd 不说明对方不可用
+		{errors.New("etcd client: no available endpoints"), false},
+	} {
+		if got := peerDown(tt.err); got != tt.down {
+			t.Errorf("peerDown(%v) = %v, want %v", tt.err, got, tt.down)
+		}
+	}
+}
+
+func TestServerSkipsUnhealthyPeers(t *testing.T) {
+	s, err := NewServer("127.0.0.1:7001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetHealthOptions(HealthOptions{FailureThreshold: 1})
+	s.SetPeers("127.0.0.1:7001", "127.0.0.1:7002", "127.0.0.1:7003")
+
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprint("key", i)
+		if s.consHash.Get(key) == "127.0.0.1:7002" {
+			break
+		}
+	}
+	down := s.clients["127.0.0.1:7002"]
+	down.report(false)
+	if h := s.PeerHealth(); h["127.0.0.1:7002"] || !h["127.0.0.1:7003"] || len(h) != 2 {
+		t.Fatalf("PeerHealth = %v", h)
+	}
+
+	// 所有者被摘除后，键改由哈希环上的下一个健康节点负责
+	peer, ok := s.PickPeer(key)
+	if ok && peer == ProtoGetter(down) {
+		t.Fatal("PickPeer returned an unhealthy peer")
+	}
+	for _, o := range s.PickOwners(key, 3) {
+		if o == ProtoGetter(down) {
+			t.Fatal("PickOwners returned an unhealthy peer")
+		}
+	}
+	if n := len(s.PickOwners(key, 3)); n != 2 {
+		t.Fatalf("PickOwners returned %d owners, want 2", n)
+	}
+}
+
+// 监听失败时 Start 释放锁，也不启动探测
+func TestServerStartListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	s, err := NewServer(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err == nil {
+		t.Fatal("Start should fail when the port is in use")
+	}
+	done := make(chan struct{})
+	go func() {
+		s.SetPeers(lis.Addr().String())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("server lock still held after Start failed")
+	}
+	if s.probeStop != nil {
+		t.Fatal("prober started although Start failed")
+	}
+}
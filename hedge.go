@@ -0,0 +1,240 @@
+// 对冲请求
+// 所有者节点偶尔变慢时，它的尾延迟会原样传递给所有请求该键的用户。
+// 开启对冲后，所有者在最近远程获取耗时的某个分位数之内仍未应答时，再向下一个副本（或本地 Getter）发出一次请求，
+// 取先成功的结果并取消另一个请求。发往副本的对冲请求只读取对方的缓存，不会被转发回变慢的所有者。
+
+package geecache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+const (
+	// hedgeWindow 是计算对冲延迟时保留的最近远程获取耗时的样本数
+	hedgeWindow = 256
+
+	// hedgeMinSamples 是使用分位数之前至少需要的样本数，样本不足时使用 MaxDelay
+	hedgeMinSamples = 20
+)
+
+// HedgeOptions 是对冲请求的配置，为零的字段使用默认值
+type HedgeOptions struct {
+	// Percentile 是对冲延迟所取的远程获取耗时分位数，取值在 (0, 1) 之间，默认为 0.95
+	Percentile float64
+
+	// MinDelay 和 MaxDelay 是对冲延迟的下限和上限，默认分别为 10ms 和 1s
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// WithHedging 为该组开启对冲请求。
+// 开启复制时第二个请求发往下一个健康的副本，只读取该副本的缓存；
+// 未开启复制或下一个副本是本节点时在本地加载，配置了 PeerFallbackFail 时不在本地加载，也就不发出对冲请求。
+func WithHedging(opts HedgeOptions) GroupOption {
+	return func(g *Group) {
+		if opts.Percentile <= 0 || opts.Percentile >= 1 {
+			opts.Percentile = 0.95
+		}
+		if opts.MinDelay <= 0 {
+			opts.MinDelay = 10 * time.Millisecond
+		}
+		if opts.MaxDelay <= 0 {
+			opts.MaxDelay = time.Second
+		}
+		if opts.MaxDelay < opts.MinDelay {
+			opts.MaxDelay = opts.MinDelay
+		}
+		g.hedge = &hedger{opts: opts}
+	}
+}
+
+// hedger 记录最近的远程获取耗时，并据此计算对冲延迟
+type hedger struct {
+	opts HedgeOptions
+
+	mu      sync.Mutex
+	samples [hedgeWindow]time.Duration
+	n       int
+	next    int
+}
+
+// observe 记录一次成功的远程获取的耗时
+func (h *hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.next] = d
+	h.next = (h.next + 1) % hedgeWindow
+	if h.n < hedgeWindow {
+		h.n++
+	}
+}
+
+// delay 返回发出对冲请求之前的等待时间
+func (h *hedger) delay() time.Duration {
+	h.mu.Lock()
+	if h.n < hedgeMinSamples {
+		h.mu.Unlock()
+		return h.opts.MaxDelay
+	}
+	sorted := make([]time.Duration, h.n)
+	copy(sorted, h.samples[:h.n])
+	h.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	d := sorted[int(float64(len(sorted)-1)*h.opts.Percentile)]
+	if d < h.opts.MinDelay {
+		d = h.opts.MinDelay
+	}
+	if d > h.opts.MaxDelay {
+		d = h.opts.MaxDelay
+	}
+	return d
+}
+
+// hedgeTarget 返回对冲请求的目标，返回 nil 表示在本地加载，ok 为 false 表示不发出对冲请求。
+// 只有开启复制时才向其他副本发出对冲请求，其他节点未命中时会把请求转发给同一个变慢的所有者
+func (g *Group) hedgeTarget(key string, owner ProtoGetter) (peer ProtoGetter, ok bool) {
+	owners, _ := g.replicaOwners(key)
+	for _, o := range owners {
+		if o == nil {
+			break
+		}
+		if o != owner && g.peerHealthy(o) {
+			return o, true
+		}
+	}
+	return nil, g.peerFallback != PeerFallbackFail
+}
+
+// hedgeFromPeer 向另一个副本发出对冲请求。请求只读取对方的缓存，
+// 对方未命中时直接返回 ErrNotFound，不会再转发给变慢的所有者。
+func (g *Group) hedgeFromPeer(ctx context.Context, peer ProtoGetter, key string, gen uint64) (ByteView, error) {
+	req := &pb.GetRequest{
+		Group:      g.name,
+		Key:        key,
+		Generation: gen,
+		CacheOnly:  true,
+	}
+	res := &pb.GetResponse{}
+	err := g.callPeer(ctx, peer, func() error {
+		return peer.Get(ctx, req, res)
+	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return g.acceptPeerResponse(key, res, gen)
+}
+
+// hedgeResult 是 getFromOwner 中一个请求的结果，from 为 nil 表示本地加载
+type hedgeResult struct {
+	value ByteView
+	from  ProtoGetter
+	err   error
+}
+
+// getFromOwner 从所有者 owner 获取 key，开启对冲时在所有者超过对冲延迟仍未应答后发出对冲请求。
+// from 是返回结果的来源，为 nil 时表示结果来自本地的对冲请求，此时统计信息已经由 hedgeLocally 更新。
+// 所有请求都失败时，本地加载过则返回本地加载的错误，否则返回所有者的错误。
+func (g *Group) getFromOwner(ctx context.Context, owner ProtoGetter, key string, gen uint64) (value ByteView, from ProtoGetter, err error) {
+	if g.hedge == nil {
+		value, err = g.getFromPeer(ctx, owner, key, gen)
+		return value, owner, err
+	}
+
+	parent := ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	hctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// 有缓冲，落败的请求返回时不会阻塞
+	results := make(chan hedgeResult, 2)
+	go func() {
+		start := time.Now()
+		v, err := g.getFromPeer(hctx, owner, key, gen)
+		if err == nil {
+			g.hedge.observe(time.Since(start))
+		}
+		results <- hedgeResult{v, owner, err}
+	}()
+
+	timer := time.NewTimer(g.hedge.delay())
+	defer timer.Stop()
+
+	var ownerErr, localErr error
+	local, pending := false, 1
+	for {
+		select {
+		case <-timer.C:
+			peer, ok := g.hedgeTarget(key, owner)
+			if !ok {
+				continue
+			}
+			g.Stats.HedgesSent.Add(1)
+			pending++
+			if peer == nil {
+				local = true
+			}
+			go func() {
+				if peer == nil {
+					v, err := g.hedgeLocally(hctx, key, gen)
+					results <- hedgeResult{v, nil, err}
+					return
+				}
+				v, err := g.hedgeFromPeer(hctx, peer, key, gen)
+				results <- hedgeResult{v, peer, err}
+			}()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if r.from != owner {
+					g.Stats.HedgesWon.Add(1)
+				}
+				return r.value, r.from, nil
+			}
+			switch r.from {
+			case owner:
+				ownerErr = r.err
+			case nil:
+				localErr = r.err
+			}
+			if pending > 0 {
+				continue
+			}
+			if local {
+				return ByteView{}, nil, localErr
+			}
+			// 包括对冲请求发出之前所有者就失败的情况，交给调用方按原来的方式处理
+			if ownerErr == nil {
+				ownerErr = r.err
+			}
+			return ByteView{}, owner, ownerErr
+		}
+	}
+}
+
+// hedgeLocally 在本地调用 Getter 作为对冲请求。本节点不是 key 的所有者，
+// 加载的值不写入 mainCache，也不推送给副本，只在足够热时像远程获取的值一样加入 hotCache。
+func (g *Group) hedgeLocally(ctx context.Context, key string, gen uint64) (ByteView, error) {
+	start := time.Now()
+	var view ByteView
+	value, err := g.getLocally(ctx, key, ByteViewSink(&view))
+	g.emit(eventLoad, Event{Key: key, Size: value.Len(), Source: SourceGetter, Duration: time.Since(start), Err: err})
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		return ByteView{}, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	value.gen = gen
+	if g.admitHot(key, 0) {
+		g.Stats.HotCacheAdmits.Add(1)
+		g.populateCache(key, value, &g.hotCache)
+	}
+	return value, nil
+}
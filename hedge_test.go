@@ -0,0 +1,171 @@
+package geecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// slowPeer 返回一个在 delay 之后应答 val 的 fakePeer，请求被取消时把上下文的错误发送到 canceled
+func slowPeer(url string, delay time.Duration, val string, canceled chan<- error) *fakePeer {
+	return &fakePeer{url: url, get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			if canceled != nil {
+				canceled <- ctx.Err()
+			}
+			return ctx.Err()
+		}
+		out.Value = []byte(val)
+		return nil
+	}}
+}
+
+func TestHedgeLocal(t *testing.T) {
+	canceled := make(chan error, 1)
+	owner := slowPeer("owner", time.Second, "owner", canceled)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithPeerPicker(fixedPicker{owner}),
+		WithHedging(HedgeOptions{MaxDelay: 20 * time.Millisecond}))
+
+	var s string
+	start := time.Now()
+	if err := g.Get(context.Background(), "a", StringSink(&s)); err != nil || s != "v:a" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("Get took %v, the hedge should have answered", d)
+	}
+	if g.Stats.HedgesSent.Get() != 1 || g.Stats.HedgesWon.Get() != 1 || loads != 1 {
+		t.Fatalf("hedges sent = %d, won = %d, loads = %d", g.Stats.HedgesSent.Get(), g.Stats.HedgesWon.Get(), loads)
+	}
+
+	// 落败的所有者请求被取消
+	select {
+	case <-canceled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("owner request was not canceled")
+	}
+
+	// 本节点不是所有者，本地对冲加载的值只进入 hotCache
+	if n := g.CacheStats(MainCache).Items; n != 0 {
+		t.Fatalf("mainCache has %d items, want 0", n)
+	}
+	if n := g.CacheStats(HotCache).Items; n != 1 {
+		t.Fatalf("hotCache has %d items, want 1", n)
+	}
+}
+
+func TestHedgeNextReplica(t *testing.T) {
+	owner := slowPeer("owner", time.Second, "owner", nil)
+	var cacheOnly bool
+	next := &fakePeer{url: "next", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		cacheOnly = in.CacheOnly
+		out.Value = []byte("next")
+		return nil
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithPeerPicker(ringPicker{[]ProtoGetter{owner, next, nil}}),
+		WithReplication(2),
+		WithHedging(HedgeOptions{MaxDelay: 20 * time.Millisecond}))
+
+	var s string
+	if err := g.Get(context.Background(), "a", StringSink(&s)); err != nil || s != "next" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if g.Stats.HedgesWon.Get() != 1 || loads != 0 || !cacheOnly {
+		t.Fatalf("hedges won = %d, loads = %d, cache only = %v", g.Stats.HedgesWon.Get(), loads, cacheOnly)
+	}
+}
+
+// 未开启复制时不向哈希环上的下一个节点对冲，而是在本地加载
+func TestHedgeWithoutReplication(t *testing.T) {
+	owner := slowPeer("owner", time.Second, "owner", nil)
+	next := slowPeer("next", time.Millisecond, "next", nil)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithPeerPicker(ringPicker{[]ProtoGetter{owner, next}}),
+		WithHedging(HedgeOptions{MaxDelay: 20 * time.Millisecond}))
+
+	var s string
+	if err := g.Get(context.Background(), "a", StringSink(&s)); err != nil || s != "v:a" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if next.gets != 0 || loads != 1 {
+		t.Fatalf("next gets = %d, loads = %d", next.gets, loads)
+	}
+}
+
+// 第二个副本未命中时不把对冲请求转发给变慢的第一个副本
+func TestHedgeSecondaryDoesNotForward(t *testing.T) {
+	var loads int32
+	nodes, peers := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2),
+		WithHedging(HedgeOptions{MaxDelay: 10 * time.Millisecond}))
+	b, c := nodes[1], nodes[2]
+	peers[0].delay = 100 * time.Millisecond
+	ctx := context.Background()
+
+	var s string
+	if err := c.Get(ctx, "miss", StringSink(&s)); err != nil || s != "v:miss" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	// 只有 c 的原始请求到达第一个副本
+	if peers[0].gets != 1 || loads != 1 || c.Stats.HedgesSent.Get() != 1 || c.Stats.HedgesWon.Get() != 0 {
+		t.Fatalf("primary gets = %d, loads = %d, hedges sent = %d, won = %d",
+			peers[0].gets, loads, c.Stats.HedgesSent.Get(), c.Stats.HedgesWon.Get())
+	}
+
+	// 第二个副本已缓存该键时由它应答
+	b.localSet("hit", []byte("cached"), time.Time{}, nil, &b.mainCache)
+	if err := c.Get(ctx, "hit", StringSink(&s)); err != nil || s != "cached" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if c.Stats.HedgesWon.Get() != 1 || peers[0].gets != 2 {
+		t.Fatalf("hedges won = %d, primary gets = %d", c.Stats.HedgesWon.Get(), peers[0].gets)
+	}
+}
+
+func TestHedgeDelay(t *testing.T) {
+	// 延迟的上下限远大于所有者的耗时，避免调度抖动触发对冲
+	fast := slowPeer("fast", 0, "fast", nil)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithPeerPicker(fixedPicker{fast}),
+		WithHedging(HedgeOptions{MinDelay: 50 * time.Millisecond, MaxDelay: 200 * time.Millisecond}))
+
+	var s string
+	for i := 0; i < hedgeMinSamples+5; i++ {
+		if err := g.Get(context.Background(), string(rune('a'+i)), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Stats.HedgesSent.Get() != 0 {
+		t.Fatalf("hedges sent = %d, want 0", g.Stats.HedgesSent.Get())
+	}
+	// 所有者的耗时远小于 MinDelay，对冲延迟取下限
+	if d := g.hedge.delay(); d != 50*time.Millisecond {
+		t.Fatalf("delay = %v, want MinDelay", d)
+	}
+}
+
+func TestHedgeFallbackFail(t *testing.T) {
+	owner := slowPeer("owner", 100*time.Millisecond, "owner", nil)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads),
+		WithPeerPicker(fixedPicker{owner}),
+		WithPeerFallback(PeerFallbackFail),
+		WithHedging(HedgeOptions{MaxDelay: 10 * time.Millisecond}))
+
+	var s string
+	if err := g.Get(context.Background(), "a", StringSink(&s)); err != nil || s != "owner" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if g.Stats.HedgesSent.Get() != 0 || loads != 0 {
+		t.Fatalf("hedges sent = %d, loads = %d", g.Stats.HedgesSent.Get(), loads)
+	}
+}
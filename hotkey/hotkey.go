@@ -0,0 +1,117 @@
+// hotkey 基于 count-min sketch 的键访问频率估计
+//
+// 每个格子保存一个指数衰减的计数值，访问频率恒定为 r（次/秒）时，
+// 计数值会收敛到 r*tau，因此用 计数值/tau 来估计访问频率。
+// count-min sketch 只会高估、不会低估，用固定大小的内存跟踪任意数量的键。
+
+package hotkey
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWidth = 1024
+	defaultDepth = 4
+)
+
+// cell 是一个带时间戳的衰减计数器
+type cell struct {
+	v float64 // 上次更新时的计数值
+	t int64   // 上次更新的时间，UnixNano
+}
+
+// Tracker 估计每个键的访问频率，并发安全。
+type Tracker struct {
+	mu    sync.Mutex
+	width int
+	cells [][]cell
+	tau   float64 // 衰减的时间常数，单位为秒
+
+	// Now 用于获取当前时间，默认为 time.Now
+	Now func() time.Time
+}
+
+// New 创建一个 Tracker，tau 是衰减的时间常数，即频率估计所覆盖的大致时间窗口。
+// width 和 depth 为零时使用默认值，width 越大误差越小，depth 越大高估的概率越小。
+func New(width, depth int, tau time.Duration) *Tracker {
+	if width <= 0 {
+		width = defaultWidth
+	}
+	if depth <= 0 {
+		depth = defaultDepth
+	}
+	cells := make([][]cell, depth)
+	for i := range cells {
+		cells[i] = make([]cell, width)
+	}
+	return &Tracker{
+		width: width,
+		cells: cells,
+		tau:   tau.Seconds(),
+		Now:   time.Now,
+	}
+}
+
+// Add 记录一次对 key 的访问，并返回更新后的频率估计（次/秒）
+func (t *Tracker) Add(key string) float64 {
+	now := t.Now().UnixNano()
+	h1, h2 := hashes(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	min := math.MaxFloat64
+	for i, row := range t.cells {
+		c := &row[t.index(h1, h2, i)]
+		c.v = t.decay(c, now) + 1
+		c.t = now
+		if c.v < min {
+			min = c.v
+		}
+	}
+	return min / t.tau
+}
+
+// Rate 返回 key 的访问频率估计（次/秒），不会记录访问
+func (t *Tracker) Rate(key string) float64 {
+	now := t.Now().UnixNano()
+	h1, h2 := hashes(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	min := math.MaxFloat64
+	for i, row := range t.cells {
+		if v := t.decay(&row[t.index(h1, h2, i)], now); v < min {
+			min = v
+		}
+	}
+	return min / t.tau
+}
+
+// decay 返回格子衰减到 now 时刻的计数值
+func (t *Tracker) decay(c *cell, now int64) float64 {
+	if c.v == 0 {
+		return 0
+	}
+	dt := float64(now-c.t) / float64(time.Second)
+	if dt <= 0 {
+		return c.v
+	}
+	return c.v * math.Exp(-dt/t.tau)
+}
+
+// index 使用双重哈希计算第 i 行的格子下标
+func (t *Tracker) index(h1, h2 uint32, i int) int {
+	return int((h1 + uint32(i)*h2) % uint32(t.width))
+}
+
+func hashes(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	// h2 为奇数，保证各行的下标不会重合
+	return uint32(sum), uint32(sum>>32) | 1
+}
@@ -0,0 +1,50 @@
+package hotkey
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	now := time.Unix(0, 0)
+	tr := New(0, 0, time.Minute)
+	tr.Now = func() time.Time { return now }
+
+	// 以每秒 10 次的频率访问 10 分钟，估计值应当接近 10
+	for i := 0; i < 6000; i++ {
+		now = now.Add(100 * time.Millisecond)
+		tr.Add("hot")
+	}
+	if rate := tr.Rate("hot"); math.Abs(rate-10) > 0.5 {
+		t.Fatalf("Rate(hot) = %v; want about 10", rate)
+	}
+	if rate := tr.Rate("cold"); rate > 0.5 {
+		t.Fatalf("Rate(cold) = %v; want about 0", rate)
+	}
+
+	// 停止访问后估计值应当衰减
+	now = now.Add(5 * time.Minute)
+	if rate := tr.Rate("hot"); rate > 0.1 {
+		t.Fatalf("Rate(hot) after idle = %v; want about 0", rate)
+	}
+}
+
+func TestManyKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	tr := New(0, 0, time.Minute)
+	tr.Now = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		tr.Add(fmt.Sprintf("key%d", i))
+	}
+	for i := 0; i < 100; i++ {
+		tr.Add("hot")
+	}
+	hot := tr.Rate("hot")
+	cold := tr.Rate("key1")
+	if hot <= cold*10 {
+		t.Fatalf("Rate(hot) = %v, Rate(key1) = %v; want hot much larger", hot, cold)
+	}
+}
@@ -0,0 +1,42 @@
+package geecache
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+func TestHotCacheAdmission(t *testing.T) {
+	var qps float64
+	peer := &fakePeer{url: "owner", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		out.Value = []byte("peer:" + in.Key)
+		out.MinuteQps = qps
+		return nil
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{peer}), WithHotCacheAdmission(5))
+	ctx := context.Background()
+	var s string
+
+	// 访问频率低于阈值的远程键不进入 hotCache，每次都向所有者获取
+	for i := 0; i < 2; i++ {
+		if err := g.Get(ctx, "a", StringSink(&s)); err != nil || s != "peer:a" {
+			t.Fatalf("Get = %q, %v", s, err)
+		}
+	}
+	if peer.gets != 2 || g.Stats.HotCacheRejects.Get() != 2 {
+		t.Fatalf("gets = %d, rejects = %d", peer.gets, g.Stats.HotCacheRejects.Get())
+	}
+
+	// 所有者报告的访问频率达到阈值后加入 hotCache
+	qps = 10
+	for i := 0; i < 2; i++ {
+		if err := g.Get(ctx, "a", StringSink(&s)); err != nil || s != "peer:a" {
+			t.Fatalf("Get = %q, %v", s, err)
+		}
+	}
+	if peer.gets != 3 || loads != 0 {
+		t.Fatalf("gets = %d, loads = %d", peer.gets, loads)
+	}
+}
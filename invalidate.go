@@ -0,0 +1,130 @@
+// 按标签和前缀失效
+// Remove 只能删除一个确切的键。当一条源数据变化时，往往需要同时失效许多无法一一列举的派生键，
+// 因此支持在加载（SetTags）或设置（Set 的 tags 参数）时为条目附加标签，
+// 并通过 RemoveByTag 和 RemovePrefix 清除所有节点上匹配的条目。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// RemoveByTag 清除本节点以及 PeerPicker.GetAll 返回的所有节点上带有标签 tag 的条目。
+// 各节点的 mainCache 和 hotCache 都会被清除，各节点返回的错误会合并后返回。
+func (g *Group) RemoveByTag(ctx context.Context, tag string) error {
+	g.peersOnce.Do(g.initPeers)
+	g.localRemoveByTag(tag)
+	return g.broadcastInvalidate(ctx, &pb.InvalidateRequest{
+		Group:  g.name,
+		Target: &pb.InvalidateRequest_Tag{Tag: tag},
+	})
+}
+
+// RemovePrefix 清除本节点以及 PeerPicker.GetAll 返回的所有节点上键以 prefix 开头的条目。
+// 除 mainCache 和 hotCache 外，磁盘缓存和负缓存中匹配的条目也会被清除。
+func (g *Group) RemovePrefix(ctx context.Context, prefix string) error {
+	g.peersOnce.Do(g.initPeers)
+	g.localRemovePrefix(prefix)
+	return g.broadcastInvalidate(ctx, &pb.InvalidateRequest{
+		Group:  g.name,
+		Target: &pb.InvalidateRequest_Prefix{Prefix: prefix},
+	})
+}
+
+// localInvalidate 按请求清除本节点上匹配的条目，返回清除的条目数
+func (g *Group) localInvalidate(req *pb.InvalidateRequest) (int, error) {
+	switch t := req.Target.(type) {
+	case *pb.InvalidateRequest_Tag:
+		return g.localRemoveByTag(t.Tag), nil
+	case *pb.InvalidateRequest_Prefix:
+		return g.localRemovePrefix(t.Prefix), nil
+	default:
+		return 0, errors.New("invalidate request has no target")
+	}
+}
+
+// localRemoveByTag 清除本节点 mainCache 和 hotCache 中带有标签 tag 的条目
+func (g *Group) localRemoveByTag(tag string) int {
+	return g.localRemoveMatching(func(key string, v ByteView) bool {
+		return v.hasTag(tag)
+	}, nil)
+}
+
+// localRemovePrefix 清除本节点上键以 prefix 开头的条目
+func (g *Group) localRemovePrefix(prefix string) int {
+	return g.localRemoveMatching(func(key string, v ByteView) bool {
+		return strings.HasPrefix(key, prefix)
+	}, func() int {
+		n := 0
+		g.negCache.each(func(key string, v ByteView) bool {
+			if strings.HasPrefix(key, prefix) {
+				g.negCache.remove(key)
+			}
+			return true
+		})
+		if g.diskCache != nil {
+			n += g.diskCache.RemovePrefix(prefix)
+		}
+		return n
+	})
+}
+
+// localRemoveMatching 清除 mainCache 和 hotCache 中 match 返回 true 的条目，
+// extra 非 nil 时在同一个临界区内调用，用于清除其他缓存。返回清除的条目数。
+func (g *Group) localRemoveMatching(match func(key string, v ByteView) bool, extra func() int) int {
+	if g.CacheBytes() <= 0 {
+		return 0
+	}
+	n := 0
+	// 与 localRemove 一样，确保清除期间没有加载正在进行，避免刚清除的数据被旧的加载结果重新填充
+	g.loadGroup.Lock(func() {
+		for _, c := range []*cache{&g.mainCache, &g.hotCache} {
+			c.each(func(key string, v ByteView) bool {
+				if match(key, v) {
+					c.remove(key)
+					n++
+				}
+				return true
+			})
+		}
+		if extra != nil {
+			n += extra()
+		}
+	})
+	g.flushEvictions()
+	return n
+}
+
+// broadcastInvalidate 并发地将失效请求发送给所有节点，返回合并后的错误
+func (g *Group) broadcastInvalidate(ctx context.Context, req *pb.InvalidateRequest) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, peer := range g.peers.GetAll() {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			var err error
+			if inv, ok := peer.(Invalidator); ok {
+				err = inv.Invalidate(ctx, req, &pb.InvalidateResponse{})
+			} else {
+				err = errors.New("peer does not support invalidation")
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("invalidate on peer %s: %w", peer.GetURL(), err))
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
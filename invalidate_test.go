@@ -0,0 +1,68 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveByTagAndPrefix(t *testing.T) {
+	var loads int32
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads++
+		if strings.HasPrefix(key, "user:") {
+			if err := SetTags(dest, "users"); err != nil {
+				return err
+			}
+		}
+		return dest.SetString("v:"+key, time.Time{})
+	})
+	nodes, peers := newTestCluster(t, 2, getter)
+	a, b := nodes[0], nodes[1]
+	ctx := context.Background()
+	var s string
+
+	// b 从所有者 a 获取的值进入 b 的 hotCache，标签随值一起传递
+	for _, k := range []string{"user:1", "user:2", "post:1"} {
+		if err := b.Get(ctx, k, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Set(ctx, "tagged", []byte("x"), time.Time{}, false, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.RemoveByTag(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"user:1", "user:2", "tagged"} {
+		if _, ok := a.Peek(k); ok {
+			t.Errorf("owner kept %s", k)
+		}
+		if _, ok := b.Peek(k); ok {
+			t.Errorf("hotCache kept %s", k)
+		}
+	}
+	if _, ok := b.Peek("post:1"); !ok {
+		t.Fatal("untagged key removed")
+	}
+
+	if err := a.RemovePrefix(ctx, "post:"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Peek("post:1"); ok {
+		t.Fatal("RemovePrefix did not reach the other node")
+	}
+
+	// 某个节点失败时返回其错误，本节点照常清除
+	a.localSet("user:3", []byte("x"), time.Time{}, []string{"users"}, &a.mainCache)
+	peers[1].down.Store(true)
+	if err := a.RemoveByTag(ctx, "users"); !errors.Is(err, errPeerDown) {
+		t.Fatalf("RemoveByTag error = %v, want errPeerDown", err)
+	}
+	if _, ok := a.Peek("user:3"); ok {
+		t.Fatal("local entry kept after a peer failed")
+	}
+}
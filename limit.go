@@ -0,0 +1,206 @@
+// 数据源保护
+// singleflight 只能合并相同键的加载，大量不同的键同时未命中时仍会并发调用 Getter，把数据源压垮。
+// 配置了 LoadLimits 的组会限制同时调用 Getter 的次数和每秒调用的次数，超出限制的调用排队等待；
+// 排队超出上限，或者无法在调用方的截止时间之前轮到时，直接返回 ErrOverloaded。
+
+package geecache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadLimits 是调用 Getter 的限制，为零的字段表示不限制
+type LoadLimits struct {
+	// MaxConcurrent 是同时调用 Getter 的最大次数，BatchGetter 的一次调用占用一次
+	MaxConcurrent int
+
+	// Rate 是每秒最多调用 Getter 的次数，BatchGetter 的一次调用按其中的键数计算
+	Rate float64
+
+	// Burst 是速率限制允许的突发次数，默认为 Rate 向上取整，至少为 1
+	Burst int
+
+	// MaxQueue 是同时排队等待的最大调用数，超出时直接拒绝
+	MaxQueue int
+
+	// MaxWait 是每次调用最长的排队时间，调用方的上下文设置了更早的截止时间时以截止时间为准
+	MaxWait time.Duration
+}
+
+// WithLoadLimits 设置调用 Getter 的并发和速率限制
+func WithLoadLimits(limits LoadLimits) GroupOption {
+	return func(g *Group) {
+		if limits.MaxConcurrent <= 0 && limits.Rate <= 0 {
+			g.limiter = nil
+			return
+		}
+		if limits.Rate > 0 && limits.Burst <= 0 {
+			limits.Burst = int(math.Ceil(limits.Rate))
+		}
+		l := &loadLimiter{g: g, limits: limits, tokens: float64(limits.Burst)}
+		if limits.MaxConcurrent > 0 {
+			l.slots = make(chan struct{}, limits.MaxConcurrent)
+		}
+		g.limiter = l
+	}
+}
+
+// loadLimiter 限制一个组调用 Getter 的并发数和速率
+type loadLimiter struct {
+	g      *Group
+	limits LoadLimits
+
+	// slots 的容量是最大并发数，为 nil 时不限制并发
+	slots chan struct{}
+
+	// queued 是正在排队的调用数，需要通过原子操作访问
+	queued int64
+
+	// 令牌桶，tokens 为负时表示已经被排队的调用预定
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// acquire 为一次消耗 n 个速率令牌的 Getter 调用取得许可，成功时返回的 release 必须在调用结束后执行
+func (l *loadLimiter) acquire(ctx context.Context, n int) (release func(), err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	now := NowFunc()
+	deadline, hasDeadline := ctx.Deadline()
+	if l.limits.MaxWait > 0 && (!hasDeadline || now.Add(l.limits.MaxWait).Before(deadline)) {
+		deadline, hasDeadline = now.Add(l.limits.MaxWait), true
+	}
+
+	waited := false
+	defer func() {
+		if waited {
+			l.g.Stats.LoadsQueued.Add(1)
+			l.g.Stats.LoadWaitTime.Add(int64(NowFunc().Sub(now)))
+		}
+		if err != nil {
+			l.g.Stats.LoadsShed.Add(1)
+		}
+	}()
+
+	if l.limits.Rate > 0 {
+		wait, ok := l.reserve(now, n, deadline, hasDeadline)
+		if !ok {
+			return nil, l.overloaded("rate", nil)
+		}
+		if wait > 0 {
+			if !l.enqueue() {
+				l.unreserve(n)
+				return nil, l.overloaded("queue", nil)
+			}
+			waited = true
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				atomic.AddInt64(&l.queued, -1)
+			case <-ctx.Done():
+				timer.Stop()
+				atomic.AddInt64(&l.queued, -1)
+				l.unreserve(n)
+				return nil, l.overloaded("rate", ctx.Err())
+			}
+		}
+	}
+
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	// 没有取得并发许可的调用不会执行，归还已经预定的速率令牌
+	defer func() {
+		if err != nil && l.limits.Rate > 0 {
+			l.unreserve(n)
+		}
+	}()
+	release = func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if !l.enqueue() {
+		return nil, l.overloaded("queue", nil)
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+	waited = true
+	var expired <-chan time.Time
+	if hasDeadline {
+		timer := time.NewTimer(deadline.Sub(NowFunc()))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-expired:
+		return nil, l.overloaded("concurrency", context.DeadlineExceeded)
+	case <-ctx.Done():
+		return nil, l.overloaded("concurrency", ctx.Err())
+	}
+}
+
+// reserve 从令牌桶中预定 n 个令牌，返回需要等待的时间。
+// 等待会超过截止时间时不预定，返回 false。
+func (l *loadLimiter) reserve(now time.Time, n int, deadline time.Time, hasDeadline bool) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.limits.Rate
+		if l.tokens > float64(l.limits.Burst) {
+			l.tokens = float64(l.limits.Burst)
+		}
+	}
+	l.last = now
+
+	// 一次调用需要的令牌超过桶的容量时按容量计算，否则永远无法满足
+	need := math.Min(float64(n), float64(l.limits.Burst))
+	var wait time.Duration
+	if l.tokens < need {
+		wait = time.Duration((need - l.tokens) / l.limits.Rate * float64(time.Second))
+	}
+	if wait > 0 && hasDeadline && now.Add(wait).After(deadline) {
+		return 0, false
+	}
+	l.tokens -= need
+	return wait, true
+}
+
+// unreserve 归还 reserve 预定的令牌
+func (l *loadLimiter) unreserve(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens += math.Min(float64(n), float64(l.limits.Burst))
+	if l.tokens > float64(l.limits.Burst) {
+		l.tokens = float64(l.limits.Burst)
+	}
+}
+
+// enqueue 在排队的调用数未达到上限时加入队列
+func (l *loadLimiter) enqueue() bool {
+	if atomic.AddInt64(&l.queued, 1) > int64(l.limits.MaxQueue) && l.limits.MaxQueue > 0 {
+		atomic.AddInt64(&l.queued, -1)
+		return false
+	}
+	return true
+}
+
+func (l *loadLimiter) overloaded(reason string, err error) error {
+	return &ErrOverloaded{Group: l.g.name, Reason: reason, Err: err}
+}
+
+// acquireLoad 在配置了 LoadLimits 时为一次消耗 n 个速率令牌的 Getter 调用取得许可
+func (g *Group) acquireLoad(ctx context.Context, n int) (release func(), err error) {
+	if g.limiter == nil {
+		return func() {}, nil
+	}
+	return g.limiter.acquire(ctx, n)
+}
@@ -0,0 +1,150 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingGetter 在 release 关闭之前阻塞，记录同时进行的调用数及其最大值
+type blockingGetter struct {
+	release   chan struct{}
+	cur, max  int32
+	completed int32
+}
+
+func (b *blockingGetter) Get(ctx context.Context, key string, dest Sink) error {
+	n := atomic.AddInt32(&b.cur, 1)
+	defer atomic.AddInt32(&b.cur, -1)
+	for {
+		m := atomic.LoadInt32(&b.max)
+		if n <= m || atomic.CompareAndSwapInt32(&b.max, m, n) {
+			break
+		}
+	}
+	<-b.release
+	atomic.AddInt32(&b.completed, 1)
+	return dest.SetString("v:"+key, time.Time{})
+}
+
+func TestLoadLimitsConcurrency(t *testing.T) {
+	bg := &blockingGetter{release: make(chan struct{})}
+	peer := &fakePeer{url: "owner"}
+	g := newTestGroup(t, bg, WithPeerPicker(prefixPicker{peer}), WithLoadLimits(LoadLimits{MaxConcurrent: 2}))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var s string
+			if err := g.Get(ctx, strconv.Itoa(i), StringSink(&s)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	waitFor(t, "queued loads", func() bool {
+		return atomic.LoadInt32(&bg.cur) == 2 && atomic.LoadInt64(&g.limiter.queued) == 2
+	})
+
+	// 限制只作用于 Getter，远程获取不受影响
+	var s string
+	if err := g.Get(ctx, "remote", StringSink(&s)); err != nil || s != "peer:remote" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+
+	close(bg.release)
+	wg.Wait()
+	if bg.max != 2 || bg.completed != 4 || g.Stats.LoadsQueued.Get() != 2 {
+		t.Fatalf("max concurrent = %d, completed = %d, queued = %d", bg.max, bg.completed, g.Stats.LoadsQueued.Get())
+	}
+}
+
+func TestLoadLimitsShed(t *testing.T) {
+	bg := &blockingGetter{release: make(chan struct{})}
+	g := newTestGroup(t, bg, WithPeerPicker(fixedPicker{}), WithLoadLimits(LoadLimits{MaxConcurrent: 1, MaxQueue: 1}))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var s string
+			if err := g.Get(ctx, strconv.Itoa(i), StringSink(&s)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		waitFor(t, "load started or queued", func() bool {
+			return int64(atomic.LoadInt32(&bg.cur))+atomic.LoadInt64(&g.limiter.queued) == int64(i+1)
+		})
+	}
+
+	// 队列已满时直接拒绝
+	var s string
+	err := g.Get(ctx, "shed", StringSink(&s))
+	var oe *ErrOverloaded
+	if !errors.As(err, &oe) || g.Stats.LoadsShed.Get() != 1 {
+		t.Fatalf("Get = %v, shed = %d, want ErrOverloaded", err, g.Stats.LoadsShed.Get())
+	}
+	close(bg.release)
+	wg.Wait()
+}
+
+func TestLoadLimitsRate(t *testing.T) {
+	clock := newFakeClock(t)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{}),
+		WithLoadLimits(LoadLimits{Rate: 20, Burst: 1, MaxWait: 10 * time.Millisecond}))
+	ctx := context.Background()
+	var s string
+	if err := g.Get(ctx, "0", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 令牌在 50ms 后才能补充，等待时间超过 MaxWait 时立即拒绝
+	var oe *ErrOverloaded
+	if err := g.Get(ctx, "1", StringSink(&s)); !errors.As(err, &oe) || oe.Reason != "rate" {
+		t.Fatalf("Get = %v, want ErrOverloaded", err)
+	}
+	clock.advance(50 * time.Millisecond)
+	if err := g.Get(ctx, "1", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 || g.Stats.LoadsQueued.Get() != 0 || g.Stats.LoadsShed.Get() != 1 {
+		t.Fatalf("loads = %d, queued = %d, shed = %d", loads, g.Stats.LoadsQueued.Get(), g.Stats.LoadsShed.Get())
+	}
+}
+
+// 在并发阶段被拒绝的调用归还已经预定的速率令牌
+func TestLoadLimitsUnreserve(t *testing.T) {
+	newFakeClock(t)
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{}),
+		WithLoadLimits(LoadLimits{MaxConcurrent: 1, Rate: 1, Burst: 2, MaxWait: 10 * time.Millisecond}))
+	l := g.limiter
+
+	release, err := l.acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var oe *ErrOverloaded
+	if _, err := l.acquire(canceled, 1); !errors.As(err, &oe) || oe.Reason != "concurrency" {
+		t.Fatalf("acquire = %v, want ErrOverloaded", err)
+	}
+	release()
+
+	// 桶中还剩一个令牌，不需要等待补充
+	release, err = l.acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("acquire after shed = %v", err)
+	}
+	release()
+}
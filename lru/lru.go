@@ -10,12 +10,13 @@ import (
 type NowFunc func() time.Time
 
 // Cache is a LRU cache. It is not safe for concurrent access.
-type Cache struct {
-	MaxEntries int                      // MaxEntries 是最大缓存条目数,零意味着没有限制。
-	ll         *list.List               // 双向链表
-	cache      map[any]*list.Element // 字典，键是字符串，值是双向链表中对应节点的指针
+// K 为键的类型，必须是可比较的；V 为值的类型。
+type Cache[K comparable, V any] struct {
+	MaxEntries int                 // MaxEntries 是最大缓存条目数,零意味着没有限制。
+	ll         *list.List          // 双向链表
+	cache      map[K]*list.Element // 字典，键是 K，值是双向链表中对应节点的指针
 	// optional and executed when an entry is purged.
-	OnEvicted func(key Key, value any) // 某条记录被移除时的回调函数，可以为 nil
+	OnEvicted func(key K, value V) // 某条记录被移除时的回调函数，可以为 nil
 
 	// Now 是缓存将用来确定的 Now() 函数
 	// 用于计算过期值的当前时间
@@ -23,13 +24,10 @@ type Cache struct {
 	Now NowFunc
 }
 
-// A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
-type Key any
-
 // 双向链表节点的数据类型
-type entry struct {
-	key    Key
-	value  any
+type entry[K comparable, V any] struct {
+	key    K
+	value  V
 	expire time.Time
 }
 
@@ -37,35 +35,37 @@ type entry struct {
 // New creates a new Cache.
 // If maxEntries is zero, the cache has no limit and it's assumed
 // that eviction is done by the caller.
-func New(maxEntries int) *Cache {
-	return &Cache{
+func New[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
 		MaxEntries: maxEntries,
 		ll:         list.New(),
-		cache:      make(map[any]*list.Element),
+		cache:      make(map[K]*list.Element),
 		Now:        time.Now,
 	}
 }
 
 // Add 向缓存中添加一个数据
-func (c *Cache) Add(key any, value any, expire time.Time) {
+func (c *Cache[K, V]) Add(key K, value V, expire time.Time) {
 	// 初始化缓存结构体中的map和双向链表
 	if c.cache == nil {
-		c.cache = make(map[any]*list.Element)
+		c.cache = make(map[K]*list.Element)
 		c.ll = list.New()
 	}
-	// 如果键已存在于缓存中，则更新其值和过期时间，并将该条目移到链表头部（表示最近访问）
-	if ee, ok := c.cache[key]; ok {
-		eee := ee.Value.(*entry)
+	// 如果键已存在于缓存中，则原地更新其值和过期时间，并将该条目移到链表头部（表示最近访问）
+	// 旧值通过 OnEvicted 通知调用方，以便其修正字节数等统计信息
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*entry[K, V])
 		if c.OnEvicted != nil {
-			c.OnEvicted(key, eee.value)
+			c.OnEvicted(key, e.value)
 		}
-		c.ll.MoveToFront(ee)
-		eee.expire = expire
-		eee.value = value
+		c.ll.MoveToFront(ele)
+		e.expire = expire
+		e.value = value
+		return
 	}
 
 	// 如果键不存在于缓存中，创建一个新的缓存条目并将其添加到链表头部和map中
-	ele := c.ll.PushFront(&entry{key, value, expire})
+	ele := c.ll.PushFront(&entry[K, V]{key, value, expire})
 	c.cache[key] = ele
 
 	// 如果设置了最大条目数且当前条目数超过最大值，则移除最老的条目
@@ -75,26 +75,47 @@ func (c *Cache) Add(key any, value any, expire time.Time) {
 }
 
 // Get 从缓存中查找键的值
-func (c *Cache) Get(key Key) (value any, ok bool) {
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 	if c.cache == nil {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
-		entry := ele.Value.(*entry)
+		e := ele.Value.(*entry[K, V])
 		// 如果该条目已过期，则将其从缓存中删除
-		if !entry.expire.IsZero() && entry.expire.Before(c.Now()) {
+		if c.expired(e) {
 			c.removeElement(ele)
-			return nil, false
+			return
 		}
 
 		c.ll.MoveToFront(ele)
-		return entry.value, true
+		return e.value, true
+	}
+	return
+}
+
+// Peek 返回键对应的值，但不会更新该条目的最近使用状态，也不会删除已过期的条目。
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		e := ele.Value.(*entry[K, V])
+		if c.expired(e) {
+			return
+		}
+		return e.value, true
 	}
 	return
 }
 
+// Contains 检查键是否存在且未过期，不会更新该条目的最近使用状态。
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
 // Remove removes the provided key from the cache.
-func (c *Cache) Remove(key Key) {
+func (c *Cache[K, V]) Remove(key K) {
 	if c.cache == nil {
 		return
 	}
@@ -104,7 +125,7 @@ func (c *Cache) Remove(key Key) {
 }
 
 // RemoveOldest 从缓存中删除最旧的项目。
-func (c *Cache) RemoveOldest() {
+func (c *Cache[K, V]) RemoveOldest() {
 	if c.cache == nil {
 		return
 	}
@@ -114,11 +135,74 @@ func (c *Cache) RemoveOldest() {
 	}
 }
 
-func (c *Cache) removeElement(e *list.Element) {
+// GetOldest 返回最旧的未过期条目，不会更新其最近使用状态。
+// 途经的已过期条目会被顺带删除。
+func (c *Cache[K, V]) GetOldest() (key K, value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	for ele := c.ll.Back(); ele != nil; ele = c.ll.Back() {
+		e := ele.Value.(*entry[K, V])
+		if c.expired(e) {
+			c.removeElement(ele)
+			continue
+		}
+		return e.key, e.value, true
+	}
+	return
+}
+
+// Keys 按从旧到新的顺序返回缓存中所有未过期的键。
+func (c *Cache[K, V]) Keys() []K {
+	if c.cache == nil {
+		return nil
+	}
+	keys := make([]K, 0, c.ll.Len())
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[K, V])
+		if c.expired(e) {
+			continue
+		}
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// Resize 修改缓存的最大条目数，并返回因此被淘汰的条目数量。
+// size 为零表示不限制条目数。
+func (c *Cache[K, V]) Resize(size int) (evicted int) {
+	c.MaxEntries = size
+	if c.cache == nil || size == 0 {
+		return 0
+	}
+	for c.ll.Len() > size {
+		c.RemoveOldest()
+		evicted++
+	}
+	return evicted
+}
+
+// RemoveExpired 删除所有已过期的条目，并返回删除的数量。
+func (c *Cache[K, V]) RemoveExpired() (removed int) {
+	if c.cache == nil {
+		return 0
+	}
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if c.expired(ele.Value.(*entry[K, V])) {
+			c.removeElement(ele)
+			removed++
+		}
+		ele = prev
+	}
+	return removed
+}
+
+func (c *Cache[K, V]) removeElement(e *list.Element) {
 	// 从双向链表中移除指定的链表元素 e
 	c.ll.Remove(e)
 	// 从缓存的 map 中删除与该链表元素对应的键值对
-	kv := e.Value.(*entry)
+	kv := e.Value.(*entry[K, V])
 	delete(c.cache, kv.key)
 	// 回调函数
 	if c.OnEvicted != nil {
@@ -126,23 +210,51 @@ func (c *Cache) removeElement(e *list.Element) {
 	}
 }
 
+// expired 判断条目是否已过期，零值的过期时间表示永不过期
+func (c *Cache[K, V]) expired(e *entry[K, V]) bool {
+	if e.expire.IsZero() {
+		return false
+	}
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+	return e.expire.Before(now())
+}
 
-// Len returns the number of items in the cache
-func (c *Cache) Len() int {
+// Entries returns the number of items stored in the cache, including expired
+// items that have not been removed yet. It runs in constant time.
+func (c *Cache[K, V]) Entries() int {
 	if c.cache == nil {
 		return 0
 	}
 	return c.ll.Len()
 }
 
+// Len returns the number of unexpired items in the cache.
+// It walks the whole list to skip expired items, so it is O(n); use Entries
+// on hot paths such as stats.
+func (c *Cache[K, V]) Len() int {
+	if c.cache == nil {
+		return 0
+	}
+	n := 0
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		if !c.expired(ele.Value.(*entry[K, V])) {
+			n++
+		}
+	}
+	return n
+}
+
 // Clear purges all stored items from the cache.
-func (c *Cache) Clear() {
+func (c *Cache[K, V]) Clear() {
 	if c.OnEvicted != nil {
 		for _, e := range c.cache {
-			kv := e.Value.(*entry)
+			kv := e.Value.(*entry[K, V])
 			c.OnEvicted(kv.key, kv.value)
 		}
 	}
 	c.ll = nil
 	c.cache = nil
-}
\ No newline at end of file
+}
@@ -31,10 +31,10 @@ var getTests = []struct {
 }
 
 func TestAdd_evictsOldAndReplaces(t *testing.T) {
-	var evictedKey Key
-	var evictedValue interface{}
-	lru := New(0)
-	lru.OnEvicted = func(key Key, value interface{}) {
+	var evictedKey string
+	var evictedValue int
+	lru := New[string, int](0)
+	lru.OnEvicted = func(key string, value int) {
 		evictedKey = key
 		evictedValue = value
 	}
@@ -54,11 +54,14 @@ func TestAdd_evictsOldAndReplaces(t *testing.T) {
 	if evictedValue != 1234 {
 		t.Fatalf("%s: evictedValue = %v; want %v", t.Name(), evictedValue, 1234)
 	}
+	if lru.Len() != 1 {
+		t.Fatalf("%s: Len() = %v; want %v", t.Name(), lru.Len(), 1)
+	}
 }
 
 func TestGet(t *testing.T) {
 	for _, tt := range getTests {
-		lru := New(0)
+		lru := New[any, int](0)
 		lru.Add(tt.keyToAdd, 1234, time.Time{})
 		val, ok := lru.Get(tt.keyToGet)
 		if ok != tt.expectedOk {
@@ -70,7 +73,7 @@ func TestGet(t *testing.T) {
 }
 
 func TestRemove(t *testing.T) {
-	lru := New(0)
+	lru := New[string, int](0)
 	lru.Add("myKey", 1234, time.Time{})
 	if val, ok := lru.Get("myKey"); !ok {
 		t.Fatal("TestRemove returned no match")
@@ -85,12 +88,12 @@ func TestRemove(t *testing.T) {
 }
 
 func TestEvict(t *testing.T) {
-	evictedKeys := make([]Key, 0)
-	onEvictedFun := func(key Key, value interface{}) {
+	evictedKeys := make([]string, 0)
+	onEvictedFun := func(key string, value int) {
 		evictedKeys = append(evictedKeys, key)
 	}
 
-	lru := New(20)
+	lru := New[string, int](20)
 	lru.OnEvicted = onEvictedFun
 	for i := 0; i < 22; i++ {
 		lru.Add(fmt.Sprintf("myKey%d", i), 1234, time.Time{})
@@ -99,10 +102,10 @@ func TestEvict(t *testing.T) {
 	if len(evictedKeys) != 2 {
 		t.Fatalf("got %d evicted keys; want 2", len(evictedKeys))
 	}
-	if evictedKeys[0] != Key("myKey0") {
+	if evictedKeys[0] != "myKey0" {
 		t.Fatalf("got %v in first evicted key; want %s", evictedKeys[0], "myKey0")
 	}
-	if evictedKeys[1] != Key("myKey1") {
+	if evictedKeys[1] != "myKey1" {
 		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey1")
 	}
 }
@@ -120,7 +123,7 @@ func TestExpire(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		lru := New(0)
+		lru := New[any, int](0)
 		lru.Add(tt.key, 1234, time.Now().Add(tt.expire))
 		time.Sleep(tt.wait)
 		val, ok := lru.Get(tt.key)
@@ -130,4 +133,77 @@ func TestExpire(t *testing.T) {
 			t.Fatalf("%s expected get to return 1234 but got %v", tt.name, val)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestPeek(t *testing.T) {
+	lru := New[string, int](0)
+	lru.Add("a", 1, time.Time{})
+	lru.Add("b", 2, time.Time{})
+
+	if val, ok := lru.Peek("a"); !ok || val != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", val, ok)
+	}
+	if !lru.Contains("b") {
+		t.Fatal("Contains(b) = false; want true")
+	}
+	if lru.Contains("c") {
+		t.Fatal("Contains(c) = true; want false")
+	}
+
+	// Peek 不应该提升 "a"，所以 "a" 仍然是最旧的条目
+	key, val, ok := lru.GetOldest()
+	if !ok || key != "a" || val != 1 {
+		t.Fatalf("GetOldest() = %v, %v, %v; want a, 1, true", key, val, ok)
+	}
+}
+
+func TestKeysAndResize(t *testing.T) {
+	lru := New[string, int](0)
+	for i := 0; i < 5; i++ {
+		lru.Add(fmt.Sprintf("myKey%d", i), i, time.Time{})
+	}
+	lru.Get("myKey0")
+
+	want := []string{"myKey1", "myKey2", "myKey3", "myKey4", "myKey0"}
+	keys := lru.Keys()
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("Keys() = %v; want %v", keys, want)
+	}
+
+	if evicted := lru.Resize(2); evicted != 3 {
+		t.Fatalf("Resize(2) evicted %d; want 3", evicted)
+	}
+	want = []string{"myKey4", "myKey0"}
+	if keys := lru.Keys(); fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("Keys() = %v; want %v", keys, want)
+	}
+}
+
+func TestLenSkipsExpired(t *testing.T) {
+	now := time.Now()
+	lru := New[string, int](0)
+	lru.Now = func() time.Time { return now }
+	lru.Add("live", 1, time.Time{})
+	lru.Add("dying", 2, now.Add(time.Second))
+
+	if lru.Len() != 2 {
+		t.Fatalf("Len() = %d; want 2", lru.Len())
+	}
+	now = now.Add(2 * time.Second)
+	if lru.Len() != 1 {
+		t.Fatalf("Len() = %d; want 1", lru.Len())
+	}
+	// Entries 仍然计入尚未删除的过期条目
+	if lru.Entries() != 2 {
+		t.Fatalf("Entries() = %d; want 2", lru.Entries())
+	}
+	if lru.Contains("dying") {
+		t.Fatal("Contains(dying) = true; want false")
+	}
+	if keys := lru.Keys(); len(keys) != 1 || keys[0] != "live" {
+		t.Fatalf("Keys() = %v; want [live]", keys)
+	}
+	if removed := lru.RemoveExpired(); removed != 1 {
+		t.Fatalf("RemoveExpired() = %d; want 1", removed)
+	}
+}
@@ -0,0 +1,241 @@
+// 全局内存管理
+// 多个 Group 可以加入同一个 MemoryPool，共享一个总的字节上限。
+// MemoryPool 会定期根据各组的命中情况重新分配内存，
+// 让空闲的组让出内存，繁忙的组获得更多内存，同时遵守每个组的最小值和最大值。
+
+package geecache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryPool 是一个在多个 Group 之间共享的内存预算
+type MemoryPool struct {
+	mu sync.Mutex
+
+	// 所有成员组的 cacheBytes 之和不超过 totalBytes
+	totalBytes int64
+
+	members map[*Group]*poolMember
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// poolMember 记录一个组在内存池中的配置以及上一次重新分配时的统计快照
+type poolMember struct {
+	minBytes, maxBytes int64
+
+	// 上一次重新分配时的累计命中数和淘汰数，用于计算区间内的增量
+	lastHits, lastEvictions int64
+}
+
+// NewMemoryPool 创建一个总上限为 totalBytes 的内存池
+func NewMemoryPool(totalBytes int64) *MemoryPool {
+	return &MemoryPool{
+		totalBytes: totalBytes,
+		members:    make(map[*Group]*poolMember),
+	}
+}
+
+// Register 将组加入内存池，minBytes 和 maxBytes 是该组可获得的内存下限和上限，
+// maxBytes 为零表示只受内存池总量限制。
+// 加入后组原有的 cacheBytes 会被内存池接管，并立即进行一次重新分配。
+func (p *MemoryPool) Register(g *Group, minBytes, maxBytes int64) error {
+	if minBytes <= 0 {
+		return errors.New("memory pool: minBytes must be positive")
+	}
+	if maxBytes == 0 {
+		maxBytes = p.totalBytes
+	}
+	if maxBytes < minBytes {
+		return fmt.Errorf("memory pool: maxBytes %d is less than minBytes %d", maxBytes, minBytes)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, dup := p.members[g]; dup {
+		return fmt.Errorf("memory pool: group %s already registered", g.name)
+	}
+	var reserved int64
+	for _, m := range p.members {
+		reserved += m.minBytes
+	}
+	if reserved+minBytes > p.totalBytes {
+		return fmt.Errorf("memory pool: minBytes of all groups exceed total %d", p.totalBytes)
+	}
+	hits, evictions := poolCounters(g)
+	p.members[g] = &poolMember{
+		minBytes:      minBytes,
+		maxBytes:      maxBytes,
+		lastHits:      hits,
+		lastEvictions: evictions,
+	}
+	p.rebalanceLocked()
+	return nil
+}
+
+// Unregister 将组移出内存池，组保留最后一次分配到的 cacheBytes
+func (p *MemoryPool) Unregister(g *Group) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.members, g)
+}
+
+// Start 启动后台协程，每隔 interval 重新分配一次内存
+func (p *MemoryPool) Start(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop(interval, p.stop, p.done)
+}
+
+// Stop 停止后台重新分配，如果没有启动则什么也不做
+func (p *MemoryPool) Stop() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (p *MemoryPool) loop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.Rebalance()
+		}
+	}
+}
+
+// Rebalance 根据各组自上次分配以来的命中情况重新分配内存
+func (p *MemoryPool) Rebalance() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rebalanceLocked()
+}
+
+// rebalanceLocked 的分配策略：
+//  1. 每个组先获得 minBytes。
+//  2. 计算每个组的需求：区间内发生过淘汰的组说明内存不足，需求为 maxBytes；
+//     否则需求为当前实际使用量加上 1/8 的余量，让空闲的组逐步让出内存。
+//  3. 剩余的内存按边际收益（区间内每字节带来的命中数）加权分配给需求尚未满足的组，
+//     直到内存用完或所有需求都被满足。
+//  4. 仍有剩余时按同样的权重继续分配，直到各组的 maxBytes，
+//     否则刚启动、还没有使用量的组只能得到 minBytes，其余预算要到下一次分配才能用上。
+func (p *MemoryPool) rebalanceLocked() {
+	if len(p.members) == 0 {
+		return
+	}
+
+	type share struct {
+		g      *Group
+		alloc  int64
+		want   int64
+		max    int64
+		weight float64
+	}
+	shares := make([]*share, 0, len(p.members))
+	remaining := p.totalBytes
+	for g, m := range p.members {
+		hits, evictions := poolCounters(g)
+		deltaHits := hits - m.lastHits
+		pressured := evictions > m.lastEvictions
+		m.lastHits, m.lastEvictions = hits, evictions
+
+		used := g.mainCache.bytes() + g.hotCache.bytes()
+		want := m.maxBytes
+		if !pressured {
+			want = clampBytes(used+used/8, m.minBytes, m.maxBytes)
+		}
+
+		// 命中数加一，避免没有命中的组权重为零而永远得不到内存
+		limit := g.CacheBytes()
+		if limit < 1 {
+			limit = 1
+		}
+		shares = append(shares, &share{
+			g:      g,
+			alloc:  m.minBytes,
+			want:   want,
+			max:    m.maxBytes,
+			weight: float64(deltaHits+1) / float64(limit),
+		})
+		remaining -= m.minBytes
+	}
+
+	// distribute 按权重把剩余的内存分配给 alloc 尚未达到 target 的组
+	distribute := func(target func(s *share) int64) {
+		for remaining > 0 {
+			var totalWeight float64
+			for _, s := range shares {
+				if s.alloc < target(s) {
+					totalWeight += s.weight
+				}
+			}
+			if totalWeight == 0 {
+				return
+			}
+			var given int64
+			for _, s := range shares {
+				if s.alloc >= target(s) {
+					continue
+				}
+				n := int64(float64(remaining) * s.weight / totalWeight)
+				if n < 1 {
+					n = 1
+				}
+				if n > target(s)-s.alloc {
+					n = target(s) - s.alloc
+				}
+				if n > remaining-given {
+					n = remaining - given
+				}
+				s.alloc += n
+				given += n
+			}
+			remaining -= given
+			if given == 0 {
+				return
+			}
+		}
+	}
+	distribute(func(s *share) int64 { return s.want })
+	distribute(func(s *share) int64 { return s.max })
+
+	for _, s := range shares {
+		s.g.setCacheBytes(s.alloc)
+	}
+}
+
+// poolCounters 返回组的 mainCache 和 hotCache 的累计命中数和淘汰数
+func poolCounters(g *Group) (hits, evictions int64) {
+	main, hot := g.mainCache.stats(), g.hotCache.stats()
+	return main.Hits + hot.Hits, main.Evictions + hot.Evictions
+}
+
+func clampBytes(n, min, max int64) int64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,55 @@
+package geecache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryPool(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		return dest.SetString(fmt.Sprintf("%0100d", 1), time.Time{})
+	})
+	busy := NewGroupWithOptions(t.Name()+"_busy", getter, WithPeerPicker(fixedPicker{}))
+	defer DeregisterGroup(busy.Name())
+	idle := NewGroupWithOptions(t.Name()+"_idle", getter, WithPeerPicker(fixedPicker{}))
+	defer DeregisterGroup(idle.Name())
+
+	p := NewMemoryPool(10000)
+	if err := p.Register(busy, 1000, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(idle, 1000, 5000); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(newTestGroup(t, getter), 9000, 0); err == nil {
+		t.Fatal("Register should fail when minBytes exceed the total")
+	}
+
+	// 刚加入、还没有使用量的组立即分到全部预算，而不是只有 minBytes
+	if total := busy.CacheBytes() + idle.CacheBytes(); total != 10000 {
+		t.Fatalf("fresh pool allocated %d bytes, want 10000", total)
+	}
+	if idle.CacheBytes() > 5000 {
+		t.Fatalf("idle group got %d bytes, above its maxBytes", idle.CacheBytes())
+	}
+
+	var s string
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 200; i++ {
+			busy.Get(context.Background(), fmt.Sprint(i), StringSink(&s))
+			busy.Get(context.Background(), fmt.Sprint(i), StringSink(&s))
+		}
+		p.Rebalance()
+	}
+	if busy.CacheBytes() <= idle.CacheBytes() {
+		t.Fatalf("busy = %d, idle = %d, want busy > idle", busy.CacheBytes(), idle.CacheBytes())
+	}
+	if total := busy.CacheBytes() + idle.CacheBytes(); total > 10000 || idle.CacheBytes() < 1000 {
+		t.Fatalf("busy = %d, idle = %d", busy.CacheBytes(), idle.CacheBytes())
+	}
+	if busy.mainCache.bytes()+busy.hotCache.bytes() > busy.CacheBytes() {
+		t.Fatal("busy group exceeds its allocation")
+	}
+}
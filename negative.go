@@ -0,0 +1,39 @@
+// 负缓存
+// 当 Getter 返回 ErrNotFound 时，把这个结果也缓存一段时间，
+// 使针对同一个不存在的键的重复请求可以直接在本地应答，而不是每次都打到数据源。
+
+package geecache
+
+// lookupNegative 检查 key 是否最近被确认为不存在，是则返回对应的 ErrNotFound
+func (g *Group) lookupNegative(key string) (error, bool) {
+	if g.negTTL <= 0 || g.CacheBytes() <= 0 {
+		return nil, false
+	}
+	v, ok := g.negCache.get(key)
+	if !ok {
+		return nil, false
+	}
+	g.Stats.NegativeHits.Add(1)
+	return &ErrNotFound{Msg: v.String()}, true
+}
+
+// populateNegative 记录 key 不存在，超出负缓存的字节预算时淘汰最旧的记录
+func (g *Group) populateNegative(key string, err error) {
+	if g.negTTL <= 0 || g.CacheBytes() <= 0 {
+		return
+	}
+	g.negCache.add(key, ByteView{s: err.Error(), e: NowFunc().Add(g.negTTL)})
+	for g.negCache.bytes() > g.negBytes {
+		if _, _, ok := g.negCache.removeOldest(); !ok {
+			return
+		}
+	}
+}
+
+// removeNegative 使 key 的不存在记录失效
+func (g *Group) removeNegative(key string) {
+	if g.negTTL <= 0 {
+		return
+	}
+	g.negCache.remove(key)
+}
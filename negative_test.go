@@ -0,0 +1,81 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+func TestNegativeCacheLocal(t *testing.T) {
+	var loads int32
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		atomic.AddInt32(&loads, 1)
+		return &ErrNotFound{Msg: "no such key"}
+	}), WithPeerPicker(fixedPicker{}), WithNegativeCache(time.Minute, 1<<10))
+
+	ctx := context.Background()
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(ctx, "a", StringSink(&s)); !errors.Is(err, &ErrNotFound{}) {
+			t.Fatalf("Get error = %v, want ErrNotFound", err)
+		}
+	}
+	if loads != 1 || g.Stats.NegativeHits.Get() != 2 {
+		t.Fatalf("loads = %d, negative hits = %d", loads, g.Stats.NegativeHits.Get())
+	}
+
+	// Set 使不存在的记录失效
+	if err := g.Set(ctx, "a", []byte("x"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get(ctx, "a", StringSink(&s)); err != nil || s != "x" {
+		t.Fatalf("Get after Set = %q, %v", s, err)
+	}
+}
+
+// 所有者返回的 ErrNotFound 经过 gRPC 后仍是 ErrNotFound，请求方不在本地回源，并记入负缓存
+func TestNegativeCacheRemote(t *testing.T) {
+	peer := &fakePeer{url: "owner", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		return fromRPCError(rpcError(&ErrNotFound{Msg: "no such key"}), "get")
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{peer}),
+		WithNegativeCache(time.Minute, 1<<10))
+
+	ctx := context.Background()
+	var s string
+	for i := 0; i < 2; i++ {
+		if err := g.Get(ctx, "a", StringSink(&s)); !errors.Is(err, &ErrNotFound{}) {
+			t.Fatalf("Get error = %v, want ErrNotFound", err)
+		}
+	}
+	if loads != 0 {
+		t.Fatalf("getter called %d times, want 0", loads)
+	}
+	if peer.gets != 1 || g.Stats.NegativeHits.Get() != 1 {
+		t.Fatalf("peer gets = %d, negative hits = %d", peer.gets, g.Stats.NegativeHits.Get())
+	}
+}
+
+func TestNegativeCacheInvalidOptions(t *testing.T) {
+	for _, tt := range []struct {
+		ttl      time.Duration
+		maxBytes int64
+	}{
+		{0, 1 << 10},
+		{time.Minute, 0},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithNegativeCache(%v, %d) did not panic", tt.ttl, tt.maxBytes)
+				}
+			}()
+			WithNegativeCache(tt.ttl, tt.maxBytes)
+		}()
+	}
+}
@@ -0,0 +1,205 @@
+// 组的可选配置
+// NewGroupWithOptions 通过函数式选项创建组，使不同需求的组可以在同一进程中共存，
+// 而不必依赖 RegisterPeerPicker、SetLogger 等包级别的全局设置。
+
+package geecache
+
+import (
+	"context"
+	"time"
+)
+
+// defaultCacheBytes 是 NewGroupWithOptions 在未指定 WithCacheBytes 时使用的缓存上限
+const defaultCacheBytes = 64 << 20
+
+// defaultRemoveConcurrency 是 Remove 在未指定 WithRemoveConcurrency 时同时向其他节点发送的请求数上限
+const defaultRemoveConcurrency = 16
+
+// RemoveMode 决定 Remove 如何对待除所有者以外的节点上的失败
+type RemoveMode int
+
+const (
+	// RemoveMustSucceed 要求所有节点都删除成功，否则返回 *RemoveError，这是默认模式
+	RemoveMustSucceed RemoveMode = iota
+
+	// RemoveBestEffort 只要求所有者删除成功，其他节点的失败只记录日志和统计信息
+	RemoveBestEffort
+)
+
+// GroupOption 是 NewGroupWithOptions 的可选配置
+type GroupOption func(*Group)
+
+// NewGroupWithOptions 创建一个组，未指定的配置使用默认值：
+// 缓存上限为 64MB，PeerPicker 和日志记录器使用包级别的设置，
+// hotCache 开启且按固定比例 1/8 淘汰，加载不设超时，值不设默认过期时间，
+// Remove 最多同时向 16 个节点发送请求且要求全部成功。
+func NewGroupWithOptions(name string, getter Getter, opts ...GroupOption) *Group {
+	return newGroup(name, defaultCacheBytes, getter, nil, opts...)
+}
+
+// WithCacheBytes 设置 mainCache 和 hotCache 大小总和的上限，不大于零时不使用缓存
+func WithCacheBytes(n int64) GroupOption {
+	return func(g *Group) {
+		g.cacheBytes = n
+	}
+}
+
+// WithPeerPicker 为该组指定 PeerPicker，代替通过 RegisterPeerPicker 注册的全局初始化函数
+func WithPeerPicker(peers PeerPicker) GroupOption {
+	return func(g *Group) {
+		g.peers = peers
+	}
+}
+
+// WithDefaultTTL 为 Getter 加载的、没有设置过期时间的值指定默认的存活时间
+func WithDefaultTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.defaultTTL = ttl
+	}
+}
+
+// WithHotCache 开启或关闭 hotCache。关闭后从远程获取的值不会在本地镜像，
+// Set 的 hotCache 参数也不再生效。
+func WithHotCache(enabled bool) GroupOption {
+	return func(g *Group) {
+		g.hotCacheDisabled = !enabled
+	}
+}
+
+// WithHotCacheRatio 设置固定比例策略下 hotCache 相对 mainCache 的比例，不大于零时使用默认值 1/8；
+// 对自适应策略而言，它决定了 hotCache 的初始目标占比
+func WithHotCacheRatio(ratio float64) GroupOption {
+	return func(g *Group) {
+		g.setEvictionPolicy(g.split.policy, ratio)
+	}
+}
+
+// WithHotCacheAdmission 设置远程键加入 hotCache 所需的最低访问频率（次/秒）。
+// 访问频率取所有者节点报告的 minute_qps 和本节点观测值中的较大值，为零时所有远程键都会加入。
+func WithHotCacheAdmission(minQPS float64) GroupOption {
+	return func(g *Group) {
+		g.hotAdmitQPS = minQPS
+	}
+}
+
+// WithEvictionPolicy 设置 mainCache 与 hotCache 之间的淘汰策略，默认为 FixedRatioEviction
+func WithEvictionPolicy(policy EvictionPolicy) GroupOption {
+	return func(g *Group) {
+		g.setEvictionPolicy(policy, g.split.hotRatio)
+	}
+}
+
+// WithLoadTimeout 限制每次调用 Getter 的时长，超时后传给 Getter 的上下文会被取消
+func WithLoadTimeout(d time.Duration) GroupOption {
+	return func(g *Group) {
+		g.loadTimeout = d
+	}
+}
+
+// WithLogger 为该组指定日志记录器，代替通过 SetLogger 设置的全局日志记录器
+func WithLogger(l Logger) GroupOption {
+	return func(g *Group) {
+		g.logger = l
+	}
+}
+
+// WithRemoveConcurrency 限制 Remove 同时向其他节点发送的删除请求数
+func WithRemoveConcurrency(n int) GroupOption {
+	return func(g *Group) {
+		g.removeConcurrency = n
+	}
+}
+
+// WithRemoveMode 设置 Remove 对除所有者以外的节点上的失败的处理方式
+func WithRemoveMode(mode RemoveMode) GroupOption {
+	return func(g *Group) {
+		g.removeMode = mode
+	}
+}
+
+// WithStaleGrace 设置条目过期后的宽限期。
+// 宽限期内 Get 会立即返回过期的旧值，并在后台通过 Getter 或所有者节点刷新一次；
+// 刷新成功时替换旧值，失败时保留旧值直到宽限期结束。
+func WithStaleGrace(d time.Duration) GroupOption {
+	return func(g *Group) {
+		g.staleGrace = d
+		g.mainCache.grace = d
+		g.hotCache.grace = d
+	}
+}
+
+// WithRefreshAhead 开启提前刷新，ratio 取值范围为 (0, 1)，超出范围时不启用。
+// 带有过期时间的条目在存活时间超过其生命周期的 ratio 比例后被访问时，
+// 会在后台通过 loadGroup 重新加载，使热点键在过期之前就得到续期。
+func WithRefreshAhead(ratio float64) GroupOption {
+	return func(g *Group) {
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 0
+		}
+		g.refreshAheadRatio = ratio
+	}
+}
+
+// WithCompression 为该组开启透明压缩，长度不小于 minSize 的值会使用 c 压缩后再缓存和发送。
+// 压缩后没有变小的值按原样保存。c 为 nil 时不压缩。
+func WithCompression(c Compressor, minSize int) GroupOption {
+	return func(g *Group) {
+		g.compressor = c
+		g.compressMin = minSize
+	}
+}
+
+// WithNegativeCache 为该组开启负缓存。
+// Getter（本地或所有者节点）返回 ErrNotFound 的键会被记录 ttl 时长，
+// 期间对该键的 Get 直接返回 ErrNotFound；负缓存占用的字节数不超过 maxBytes。
+// 对该键的 Set 和 Remove 会使记录失效。与其他缓存一样，cacheBytes 不大于零时不生效。
+func WithNegativeCache(ttl time.Duration, maxBytes int64) GroupOption {
+	if ttl <= 0 || maxBytes <= 0 {
+		panic("WithNegativeCache requires positive ttl and maxBytes")
+	}
+	return func(g *Group) {
+		g.negTTL = ttl
+		g.negBytes = maxBytes
+	}
+}
+
+// WithDiskCache 为该组开启磁盘二级缓存，数据保存在 dir 下以组名命名的子目录中，
+// maxBytes 限制磁盘上存活数据的字节数。
+// 开启后，从 mainCache 中淘汰的条目会连同过期时间写入磁盘，lookupCache 在内存未命中时会先查询磁盘再加载。
+// 目录无法打开时只记录日志，该组不使用磁盘缓存；DeregisterGroup 时关闭磁盘缓存并删除其中的数据。
+func WithDiskCache(dir string, maxBytes int64) GroupOption {
+	if dir == "" || maxBytes <= 0 {
+		panic("WithDiskCache requires a dir and positive maxBytes")
+	}
+	return func(g *Group) {
+		g.diskDir = dir
+		g.diskBytes = maxBytes
+	}
+}
+
+// getLogger 返回该组使用的日志记录器，没有单独指定时使用全局的 logger，可能为 nil
+func (g *Group) getLogger() Logger {
+	if g.logger != nil {
+		return g.logger
+	}
+	return logger
+}
+
+// loadContext 返回调用 Getter 时使用的上下文，设置了加载超时时附加超时
+func (g *Group) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if g.loadTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.loadTimeout)
+}
+
+// withDefaultTTL 为没有过期时间的值加上默认的过期时间
+func (g *Group) withDefaultTTL(v ByteView) ByteView {
+	if g.defaultTTL > 0 && v.e.IsZero() {
+		v.e = NowFunc().Add(g.defaultTTL)
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package geecache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGroupOptions(t *testing.T) {
+	var sawDeadline bool
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		_, sawDeadline = ctx.Deadline()
+		return dest.SetString("v:"+key, time.Time{})
+	})
+	peer := &fakePeer{url: "owner"}
+	g := newTestGroup(t, getter, WithCacheBytes(1000), WithDefaultTTL(time.Minute), WithLoadTimeout(time.Second),
+		WithHotCache(false), WithPeerPicker(prefixPicker{peer}))
+	ctx := context.Background()
+	var s string
+
+	// 本地加载带有超时，值使用默认的过期时间
+	if err := g.Get(ctx, "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	v, ok := g.Peek("k")
+	if !ok || !sawDeadline || v.Expire().IsZero() || g.CacheBytes() != 1000 {
+		t.Fatalf("deadline = %v, expire = %v, cacheBytes = %d", sawDeadline, v.Expire(), g.CacheBytes())
+	}
+
+	// 关闭 hotCache 后远程值不在本地镜像
+	for i := 0; i < 2; i++ {
+		if err := g.Get(ctx, "remote", StringSink(&s)); err != nil || s != "peer:remote" {
+			t.Fatalf("Get = %q, %v", s, err)
+		}
+	}
+	if peer.gets != 2 || g.CacheStats(HotCache).Items != 0 {
+		t.Fatalf("gets = %d, hotCache items = %d", peer.gets, g.CacheStats(HotCache).Items)
+	}
+}
+
+func TestGroupOptionsDefaults(t *testing.T) {
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads))
+	if g.CacheBytes() != defaultCacheBytes || g.hotCacheDisabled || g.loadTimeout != 0 || g.defaultTTL != 0 {
+		t.Fatalf("unexpected defaults: cacheBytes = %d", g.CacheBytes())
+	}
+	if g.split.policy != FixedRatioEviction || g.split.hotRatio != defaultHotRatio || g.removeMode != RemoveMustSucceed {
+		t.Fatalf("unexpected defaults: policy = %v, ratio = %v", g.split.policy, g.split.hotRatio)
+	}
+}
@@ -34,6 +34,35 @@ type ProtoGetter interface {
 }
 // 通过实现 ProtoGetter 接口，每个 peer 可以成为缓存系统的一部分，负责处理缓存项的获取、删除和设置操作，并提供自己的标识（URL）
 
+// BatchProtoGetter 是 ProtoGetter 的可选扩展，实现了它的 peer 可以在一次请求中获取多个缓存项。
+// Group.GetMany 对不支持批量请求的 peer 会逐个调用 Get。
+type BatchProtoGetter interface {
+	GetMany(context context.Context, in *pb.GetManyRequest, out *pb.GetManyResponse) error
+}
+
+// Purger 是 ProtoGetter 的可选扩展，实现了它的 peer 可以接收整组清除的请求
+type Purger interface {
+	Purge(context context.Context, in *pb.PurgeRequest, out *pb.PurgeResponse) error
+}
+
+// Invalidator 是 ProtoGetter 的可选扩展，实现了它的 peer 可以接收按标签或前缀失效的请求。
+// Group.RemoveByTag 和 Group.RemovePrefix 对不支持的 peer 会返回错误。
+type Invalidator interface {
+	Invalidate(context context.Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error
+}
+
+// Replicator 是 ProtoGetter 的可选扩展，实现了它的 peer 可以接收副本。
+// 接收方只把值写入自己的 mainCache，不写入数据源，也不转发给其他节点。
+type Replicator interface {
+	Replicate(context context.Context, in *pb.SetRequest) error
+}
+
+// OwnerPicker 是 PeerPicker 的可选扩展，按哈希环上的顺序返回 key 的前 n 个所有者，第一个即 PickPeer 选择的节点。
+// 所有者是本节点时对应的元素为 nil。
+type OwnerPicker interface {
+	PickOwners(key string, n int) []ProtoGetter
+}
+
 // 实现 ProtoGetter 接口时，可以选择使用不同的方法签名，
 // 只要确保实现了 ProtoGetter 接口的 Get 方法的名字和 proto 文件中定义的一样即可。
 // 因为 gRPC 生成的代码在内部会处理输入和输出参数的映射。
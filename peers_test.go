@@ -0,0 +1,239 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestGroup 创建一个以测试名称命名的组，测试结束时注销
+func newTestGroup(t *testing.T, getter Getter, opts ...GroupOption) *Group {
+	t.Helper()
+	name := strings.ReplaceAll(t.Name(), "/", "_")
+	g := NewGroupWithOptions(name, getter, opts...)
+	t.Cleanup(func() { DeregisterGroup(name) })
+	return g
+}
+
+// countingGetter 返回一个记录调用次数的 Getter，值为 "v:" 加上键
+func countingGetter(calls *int32) GetterFunc {
+	return func(ctx context.Context, key string, dest Sink) error {
+		atomic.AddInt32(calls, 1)
+		return dest.SetString("v:"+key, time.Time{})
+	}
+}
+
+// fakePeer 是测试用的 ProtoGetter，get 为 nil 时返回 "peer:" 加上键
+type fakePeer struct {
+	url string
+	get func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error
+
+	gets, sets, removes int32
+}
+
+func (p *fakePeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	atomic.AddInt32(&p.gets, 1)
+	if p.get != nil {
+		return p.get(ctx, in, out)
+	}
+	out.Value = []byte("peer:" + in.Key)
+	return nil
+}
+
+func (p *fakePeer) Set(ctx context.Context, in *pb.SetRequest) error {
+	atomic.AddInt32(&p.sets, 1)
+	return nil
+}
+
+func (p *fakePeer) Remove(ctx context.Context, in *pb.GetRequest) error {
+	atomic.AddInt32(&p.removes, 1)
+	return nil
+}
+
+func (p *fakePeer) GetURL() string { return p.url }
+
+// nodePeer 把请求交给同一进程中代表另一个节点的组处理，
+// 错误经过 rpcError 和 fromRPCError 转换，与通过 gRPC 传输时相同。delay 非零时 Get 先等待 delay
+type nodePeer struct {
+	url   string
+	g     *Group
+	down  atomic.Bool
+	delay time.Duration
+
+	gets, replicates int32
+}
+
+var errPeerDown = errors.New("peer down")
+
+func (p *nodePeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	atomic.AddInt32(&p.gets, 1)
+	if p.down.Load() {
+		return errPeerDown
+	}
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var view ByteView
+	if in.CacheOnly {
+		v, ok := p.g.lookupReplica(in.Key)
+		if !ok {
+			return fromRPCError(rpcError(&ErrNotFound{Msg: "not cached"}), "get")
+		}
+		view = v
+	} else {
+		p.g.adoptGeneration(in.Generation)
+		if err := p.g.Get(ctx, in.Key, ByteViewSink(&view)); err != nil {
+			return fromRPCError(rpcError(err), "get")
+		}
+	}
+	*out = *newGetResponse(p.g, in.Key, view)
+	return nil
+}
+
+func (p *nodePeer) GetMany(ctx context.Context, in *pb.GetManyRequest, out *pb.GetManyResponse) error {
+	if p.down.Load() {
+		return errPeerDown
+	}
+	p.g.GetMany(ctx, in.Keys, func(key string, view ByteView, err error) {
+		r := &pb.GetManyResult{}
+		if err != nil {
+			r.Error = err.Error()
+			r.NotFound = errors.Is(err, &ErrNotFound{})
+		} else {
+			r.Value = newGetResponse(p.g, key, view)
+		}
+		out.Results = append(out.Results, r)
+	})
+	out.Generation = p.g.generation()
+	return nil
+}
+
+func (p *nodePeer) Set(ctx context.Context, in *pb.SetRequest) error {
+	if p.down.Load() {
+		return errPeerDown
+	}
+	return fromRPCError(rpcError(p.g.acceptSet(ctx, in)), "set")
+}
+
+func (p *nodePeer) Remove(ctx context.Context, in *pb.GetRequest) error {
+	if p.down.Load() {
+		return errPeerDown
+	}
+	return fromRPCError(rpcError(p.g.acceptRemove(ctx, in.Key)), "remove")
+}
+
+func (p *nodePeer) Replicate(ctx context.Context, in *pb.SetRequest) error {
+	atomic.AddInt32(&p.replicates, 1)
+	if p.down.Load() {
+		return errPeerDown
+	}
+	return p.g.acceptReplica(in)
+}
+
+func (p *nodePeer) Invalidate(ctx context.Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error {
+	if p.down.Load() {
+		return errPeerDown
+	}
+	_, err := p.g.localInvalidate(in)
+	return err
+}
+
+func (p *nodePeer) Purge(ctx context.Context, in *pb.PurgeRequest, out *pb.PurgeResponse) error {
+	if p.down.Load() {
+		return errPeerDown
+	}
+	p.g.adoptGeneration(in.Generation)
+	out.Generation = p.g.generation()
+	return nil
+}
+
+func (p *nodePeer) GetURL() string { return p.url }
+
+// fixedPicker 把所有键都交给 peer，peer 为 nil 时所有键都由本节点负责
+type fixedPicker struct{ peer ProtoGetter }
+
+func (f fixedPicker) PickPeer(key string) (ProtoGetter, bool) {
+	return f.peer, f.peer != nil
+}
+
+func (f fixedPicker) GetAll() []ProtoGetter {
+	if f.peer == nil {
+		return nil
+	}
+	return []ProtoGetter{f.peer}
+}
+
+// ringPicker 对所有键使用相同的所有者顺序 owners，元素为 nil 表示本节点
+type ringPicker struct{ owners []ProtoGetter }
+
+func (r ringPicker) PickPeer(key string) (ProtoGetter, bool) {
+	return r.owners[0], r.owners[0] != nil
+}
+
+func (r ringPicker) PickOwners(key string, n int) []ProtoGetter {
+	if n > len(r.owners) {
+		n = len(r.owners)
+	}
+	return r.owners[:n]
+}
+
+func (r ringPicker) GetAll() []ProtoGetter {
+	var peers []ProtoGetter
+	for _, o := range r.owners {
+		if o != nil {
+			peers = append(peers, o)
+		}
+	}
+	return peers
+}
+
+func TestRPCErrorRoundTrip(t *testing.T) {
+	err := fromRPCError(rpcError(&ErrNotFound{Msg: "no such key"}), "get")
+	if !errors.Is(err, &ErrNotFound{}) || err.Error() != "no such key" {
+		t.Fatalf("NotFound round trip = %v", err)
+	}
+	err = fromRPCError(rpcError(errors.New("db down")), "get")
+	if !errors.Is(err, &ErrRemoteCall{}) || err.Error() != "db down" {
+		t.Fatalf("remote error round trip = %v", err)
+	}
+	err = fromRPCError(rpcError(context.DeadlineExceeded), "get")
+	if errors.Is(err, &ErrRemoteCall{}) || !peerDown(err) {
+		t.Fatalf("deadline round trip = %v", err)
+	}
+	err = fromRPCError(errPeerDown, "get")
+	if !errors.Is(err, errPeerDown) {
+		t.Fatalf("transport error not wrapped: %v", err)
+	}
+}
+
+// 服务端拒绝的 Invalidate 和 Replicate 请求以 codes.Internal 返回，请求方还原为 ErrRemoteCall
+func TestServerRejectsWithRPCError(t *testing.T) {
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		return dest.SetString("v", time.Time{})
+	}), WithPeerPicker(fixedPicker{}))
+	s, err := NewServer("127.0.0.1:7001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = s.Invalidate(ctx, &pb.InvalidateRequest{Group: g.name})
+	if status.Code(err) != codes.Internal || !errors.Is(fromRPCError(err, "invalidate"), &ErrRemoteCall{}) {
+		t.Fatalf("Invalidate without target = %v", err)
+	}
+	_, err = s.Replicate(ctx, &pb.SetRequest{Group: g.name})
+	if status.Code(err) != codes.Internal || !errors.Is(fromRPCError(err, "replicate"), &ErrRemoteCall{}) {
+		t.Fatalf("Replicate without key = %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// 整组清除
+// 每个组维护一个代数（generation），缓存中的条目记录写入时的代数。
+// Purge 将代数加一并广播给所有节点，代数小于当前代数的条目立即被视为未命中，
+// 其内存在被访问或淘汰时再回收。代数同时随节点间的请求和响应传递，
+// 错过广播的节点在下一次与其他节点通信时就会跟上，不会再用旧数据填充缓存。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// errOlderGeneration 表示远程节点返回的数据属于已被清除的代数
+var errOlderGeneration = errors.New("peer returned data from an older generation")
+
+// Purge 清除本节点以及 PeerPicker.GetAll 返回的所有节点上该组的全部缓存。
+// 各节点返回的错误会合并后返回；未收到请求的节点会在之后与其他节点通信时跟上新的代数。
+func (g *Group) Purge(ctx context.Context) error {
+	g.peersOnce.Do(g.initPeers)
+	gen := g.generation() + 1
+	g.adoptGeneration(gen)
+
+	req := &pb.PurgeRequest{
+		Group:      g.name,
+		Generation: gen,
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, peer := range g.peers.GetAll() {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			var err error
+			if p, ok := peer.(Purger); ok {
+				err = p.Purge(ctx, req, &pb.PurgeResponse{})
+			} else {
+				err = errors.New("peer does not support purge")
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("purge on peer %s: %w", peer.GetURL(), err))
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// generation 返回组当前的代数
+func (g *Group) generation() uint64 {
+	return atomic.LoadUint64(&g.gen)
+}
+
+// adoptGeneration 在 gen 大于当前代数时切换到 gen。
+// mainCache 和 hotCache 中的旧条目延迟回收，负缓存和磁盘缓存则立即清空。
+func (g *Group) adoptGeneration(gen uint64) {
+	for {
+		cur := atomic.LoadUint64(&g.gen)
+		if gen <= cur {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&g.gen, cur, gen) {
+			break
+		}
+	}
+	g.Stats.Purges.Add(1)
+	g.loadGroup.Lock(func() {
+		g.negCache.each(func(key string, v ByteView) bool {
+			g.negCache.remove(key)
+			return true
+		})
+		if g.diskCache != nil {
+			g.diskCache.RemovePrefix("")
+		}
+	})
+}
+
+// purged 判断条目是否属于已被清除的代数
+func (g *Group) purged(v ByteView) bool {
+	return v.gen < g.generation()
+}
+
+// getLive 从缓存 c 中查找 key，已被清除的条目会被顺便删除并按未命中处理
+func (g *Group) getLive(c *cache, key string) (ByteView, bool) {
+	v, ok := c.get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	if g.purged(v) {
+		c.remove(key)
+		return ByteView{}, false
+	}
+	return v, true
+}
@@ -0,0 +1,109 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+func TestPurge(t *testing.T) {
+	var loads int32
+	nodes, peers := newTestCluster(t, 2, countingGetter(&loads))
+	a, b := nodes[0], nodes[1]
+	ctx := context.Background()
+	var s string
+
+	for _, k := range []string{"k1", "k2"} {
+		if err := b.Get(ctx, k, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.generation() != 1 || b.generation() != 1 {
+		t.Fatalf("generations = %d, %d, want 1", a.generation(), b.generation())
+	}
+	for _, k := range []string{"k1", "k2"} {
+		if _, ok := a.Peek(k); ok {
+			t.Errorf("owner still serves %s", k)
+		}
+		if _, ok := b.Peek(k); ok {
+			t.Errorf("hotCache still serves %s", k)
+		}
+	}
+	if err := b.Get(ctx, "k1", StringSink(&s)); err != nil || loads != 3 {
+		t.Fatalf("Get after Purge = %q, %v, loads = %d", s, err, loads)
+	}
+
+	// 错过广播的节点在下一次通信时跟上新的代数
+	peers[1].down.Store(true)
+	if err := a.Purge(ctx); !errors.Is(err, errPeerDown) {
+		t.Fatalf("Purge error = %v, want errPeerDown", err)
+	}
+	peers[1].down.Store(false)
+	if err := b.Get(ctx, "k2", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if b.generation() != 2 {
+		t.Fatalf("lagging node generation = %d, want 2", b.generation())
+	}
+}
+
+// 所有者返回的数据属于旧代数时按失败处理，改为在本地加载
+func TestPurgeOlderGenerationFromPeer(t *testing.T) {
+	peer := &fakePeer{url: "owner", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		out.Value = []byte("stale")
+		out.Generation = in.Generation - 1
+		return nil
+	}}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{peer}))
+	g.adoptGeneration(5)
+	var s string
+	if err := g.Get(context.Background(), "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v, want the locally loaded value", s, err)
+	}
+	if v, ok := g.hotCache.peek("k"); ok {
+		t.Fatalf("hotCache holds %q from an older generation", v.String())
+	}
+	if loads != 1 || peer.gets != 1 {
+		t.Fatalf("loads = %d, gets = %d", loads, peer.gets)
+	}
+}
+
+// 已被清除但尚未回收的条目在淘汰时不写入磁盘缓存
+func TestPurgeSkipsDiskSpill(t *testing.T) {
+	var loads int32
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString(fmt.Sprintf("%0100d", loads), time.Time{})
+	}), WithCacheBytes(400), WithPeerPicker(fixedPicker{}), WithDiskCache(t.TempDir(), 1<<20))
+	ctx := context.Background()
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(ctx, fmt.Sprint("k", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 加载新的键使 k0 等旧条目被淘汰
+	for i := 3; i < 10; i++ {
+		if err := g.Get(ctx, fmt.Sprint("k", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := loads
+	if err := g.Get(ctx, "k0", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if loads != before+1 || g.Stats.DiskHits.Get() != 0 {
+		t.Fatalf("k0 served from disk after Purge: loads = %d -> %d, disk hits = %d", before, loads, g.Stats.DiskHits.Get())
+	}
+}
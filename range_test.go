@@ -0,0 +1,59 @@
+package geecache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRangePeek(t *testing.T) {
+	var loads int32
+	peer := &fakePeer{url: "owner"}
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(prefixPicker{peer}))
+	ctx := context.Background()
+	var s string
+	for _, k := range []string{"user:1", "user:2", "post:1", "remote:1"} {
+		if err := g.Get(ctx, k, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collect := func(which CacheType, prefix string) string {
+		var keys []string
+		g.RangePrefix(which, prefix, func(key string, v ByteView) bool {
+			keys = append(keys, key)
+			return true
+		})
+		sort.Strings(keys)
+		return strings.Join(keys, ",")
+	}
+	if got := collect(MainCache, "user:"); got != "user:1,user:2" {
+		t.Fatalf("RangePrefix(MainCache) = %s", got)
+	}
+	if got := collect(HotCache, ""); got != "remote:1" {
+		t.Fatalf("Range(HotCache) = %s", got)
+	}
+	n := 0
+	g.Range(MainCache, func(key string, v ByteView) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("Range visited %d keys after fn returned false", n)
+	}
+
+	// Peek 不加载、不影响统计
+	before := g.CacheStats(MainCache)
+	if v, ok := g.Peek("post:1"); !ok || v.String() != "v:post:1" {
+		t.Fatalf("Peek = %q, %v", v.String(), ok)
+	}
+	if v, ok := g.Peek("remote:1"); !ok || v.String() != "peer:remote:1" {
+		t.Fatalf("Peek = %q, %v", v.String(), ok)
+	}
+	if _, ok := g.Peek("missing"); ok {
+		t.Fatal("Peek found a key that was never loaded")
+	}
+	if g.CacheStats(MainCache) != before || loads != 3 || peer.gets != 1 {
+		t.Fatalf("Peek changed stats or loaded: loads = %d, gets = %d", loads, peer.gets)
+	}
+}
@@ -0,0 +1,54 @@
+package geecache
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	clock := newFakeClock(t)
+	var calls int32
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		n := atomic.AddInt32(&calls, 1)
+		return dest.SetString(strconv.Itoa(int(n)), clock.now().Add(10*time.Second))
+	}), WithPeerPicker(fixedPicker{}), WithRefreshAhead(0.8))
+	ctx := context.Background()
+	get := func() string {
+		var s string
+		if err := g.Get(ctx, "k", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	get()
+	clock.advance(5 * time.Second)
+	get()
+	if n := g.Stats.RefreshesTriggered.Get(); n != 0 {
+		t.Fatalf("refreshed after half the lifetime, triggered = %d", n)
+	}
+
+	// 超过生命周期的 80% 后，访问仍返回当前值，并在后台续期
+	clock.advance(4 * time.Second)
+	if s := get(); s != "1" {
+		t.Fatalf("Get = %q, want 1", s)
+	}
+	waitFor(t, "refresh", func() bool { return g.Stats.RefreshesSucceeded.Get() == 1 })
+	if s := get(); s != "2" {
+		t.Fatalf("Get = %q, want the refreshed value", s)
+	}
+}
+
+func TestRefreshAheadRatio(t *testing.T) {
+	var loads int32
+	for _, ratio := range []float64{-1, 0, 1, 2} {
+		g := NewGroupWithOptions(t.Name(), countingGetter(&loads), WithRefreshAhead(ratio))
+		DeregisterGroup(t.Name())
+		if g.refreshAheadRatio != 0 {
+			t.Errorf("WithRefreshAhead(%v) enabled refresh-ahead", ratio)
+		}
+	}
+}
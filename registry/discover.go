@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"context"
+
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/resolver"
 	"google.golang.org/grpc"
@@ -9,6 +11,11 @@ import (
 // EtcdDial 向grpc请求一个服务
 // 用于在 gRPC 客户端中建立连接的函数，通过提供一个etcd client和service name即可获得Connection
 func EtcdDial(c *clientv3.Client, service string) (*grpc.ClientConn, error) {
+	return EtcdDialContext(context.Background(), c, service)
+}
+
+// EtcdDialContext 与 EtcdDial 相同，但在 ctx 结束时放弃等待连接建立
+func EtcdDialContext(ctx context.Context, c *clientv3.Client, service string) (*grpc.ClientConn, error) {
 	// 创建一个 etcd 解析器
 	// 该解析器用于解析服务名称到实际地址的映射
 	etcdResolver, err := resolver.NewBuilder(c)
@@ -17,7 +24,8 @@ func EtcdDial(c *clientv3.Client, service string) (*grpc.ClientConn, error) {
 	}
 
 	// 1 , creds := insecure.NewCredentials()
-	return grpc.Dial(
+	return grpc.DialContext(
+		ctx,
 		// 使用 "etcd:///" 前缀来告诉 gRPC 使用 etcd 解析器
 		"etcd:///"+service,
 		grpc.WithResolvers(etcdResolver),
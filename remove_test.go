@@ -0,0 +1,109 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+var errRemoveFailed = errors.New("remove failed")
+
+// removePeer 是 Remove 可以失败的 fakePeer，并记录同时进行的删除请求数的最大值
+type removePeer struct {
+	fakePeer
+	fail          bool
+	inflight, max *int32
+}
+
+func (p *removePeer) Remove(ctx context.Context, in *pb.GetRequest) error {
+	n := atomic.AddInt32(p.inflight, 1)
+	defer atomic.AddInt32(p.inflight, -1)
+	for {
+		m := atomic.LoadInt32(p.max)
+		if n <= m || atomic.CompareAndSwapInt32(p.max, m, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(5 * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if p.fail {
+		return errRemoveFailed
+	}
+	return nil
+}
+
+// allPeersPicker 由本节点负责所有键，GetAll 返回 peers
+type allPeersPicker struct{ peers []ProtoGetter }
+
+func (p allPeersPicker) PickPeer(key string) (ProtoGetter, bool) { return nil, false }
+func (p allPeersPicker) GetAll() []ProtoGetter                   { return p.peers }
+
+func TestRemoveError(t *testing.T) {
+	var inflight, max int32
+	var peers []ProtoGetter
+	for i := 0; i < 10; i++ {
+		peers = append(peers, &removePeer{
+			fakePeer: fakePeer{url: fmt.Sprint("peer", i)},
+			fail:     i%3 == 0,
+			inflight: &inflight,
+			max:      &max,
+		})
+	}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(allPeersPicker{peers}), WithRemoveConcurrency(3))
+	ctx := context.Background()
+
+	err := g.Remove(ctx, "k")
+	var re *RemoveError
+	if !errors.As(err, &re) || !errors.Is(err, errRemoveFailed) {
+		t.Fatalf("Remove error = %v, want *RemoveError", err)
+	}
+	var failed []string
+	for _, p := range re.Peers {
+		failed = append(failed, p.Peer)
+	}
+	sort.Strings(failed)
+	if re.Key != "k" || strings.Join(failed, ",") != "peer0,peer3,peer6,peer9" {
+		t.Fatalf("RemoveError = %v", re)
+	}
+	if n := atomic.LoadInt32(&max); n > 3 {
+		t.Fatalf("%d concurrent removes, want at most 3", n)
+	}
+
+	// 取消的上下文使所有节点失败
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := g.Remove(cctx, "k"); !errors.As(err, &re) || len(re.Peers) != 10 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Remove with a cancelled context = %v", err)
+	}
+}
+
+func TestRemoveBestEffort(t *testing.T) {
+	var inflight, max int32
+	peers := []ProtoGetter{
+		&removePeer{fakePeer: fakePeer{url: "ok"}, inflight: &inflight, max: &max},
+		&removePeer{fakePeer: fakePeer{url: "bad"}, fail: true, inflight: &inflight, max: &max},
+	}
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(allPeersPicker{peers}), WithRemoveMode(RemoveBestEffort))
+	var s string
+	if err := g.Get(context.Background(), "k", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Remove(context.Background(), "k"); err != nil {
+		t.Fatalf("Remove = %v, want nil in best-effort mode", err)
+	}
+	if _, ok := g.Peek("k"); ok || g.Stats.RemovePeerErrors.Get() != 1 {
+		t.Fatalf("peer errors = %d", g.Stats.RemovePeerErrors.Get())
+	}
+}
@@ -0,0 +1,204 @@
+// 多副本
+// 所有者宕机后，它持有的所有键都要重新回源。配置了副本数 n 的组把每个键放在哈希环上的前 n 个所有者（副本）上：
+// 所有节点（包括其余副本）都从第一个熔断器未打开的副本读取，只有它调用 Getter，加载或 Set 之后把值推送给其余副本；
+// 它失败时依次尝试其余副本。本节点成为第一个可用的副本时，先向其他副本查找，都没有缓存时才调用 Getter。
+// 需要 PeerPicker 实现 OwnerPicker，推送副本需要 peer 实现 Replicator。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+// replicateTimeout 是推送一个副本的超时时间
+const replicateTimeout = 5 * time.Second
+
+// WithReplication 设置每个键的副本数，不大于 1 时不复制。PeerPicker 没有实现 OwnerPicker 时该设置无效。
+func WithReplication(n int) GroupOption {
+	return func(g *Group) {
+		g.replicas = n
+	}
+}
+
+// replicaOwners 返回 key 的各个副本，self 表示本节点是否是其中之一。未开启复制时返回 nil。
+func (g *Group) replicaOwners(key string) (owners []ProtoGetter, self bool) {
+	if g.replicas <= 1 {
+		return nil, false
+	}
+	op, ok := g.peers.(OwnerPicker)
+	if !ok {
+		return nil, false
+	}
+	owners = op.PickOwners(key, g.replicas)
+	for _, o := range owners {
+		if o == nil {
+			self = true
+		}
+	}
+	return owners, self
+}
+
+// pickOwner 选择从哪个节点获取 key，ok 为 false 表示由本节点加载。
+// 开启复制时按顺序选择第一个熔断器未打开的副本，所有副本都不可用时选择第一个副本。
+// 本节点只向排在自己之前的副本请求，因此副本之间不会互相转发，同一个键只有一个副本调用 Getter。
+func (g *Group) pickOwner(key string) (ProtoGetter, bool) {
+	owners, _ := g.replicaOwners(key)
+	if len(owners) == 0 {
+		return g.peers.PickPeer(key)
+	}
+	for _, o := range owners {
+		if o == nil {
+			return nil, false
+		}
+		if g.peerHealthy(o) {
+			return o, true
+		}
+	}
+	return owners[0], true
+}
+
+// getFromReplicas 在从 failed 获取 key 失败后依次向其余副本请求，成功时返回 true。
+// 本节点是副本之一时只尝试排在自己之前的副本，之后由本节点加载。
+func (g *Group) getFromReplicas(ctx context.Context, key string, gen uint64, failed ProtoGetter) (ByteView, bool) {
+	owners, _ := g.replicaOwners(key)
+	for _, o := range owners {
+		if o == nil {
+			break
+		}
+		if o == failed || !g.peerHealthy(o) {
+			continue
+		}
+		value, err := g.getFromPeer(ctx, o, key, gen)
+		if err == nil {
+			g.Stats.PeerLoads.Add(1)
+			g.Stats.ReplicaFailovers.Add(1)
+			return value, true
+		}
+		if ctx != nil && ctx.Err() != nil {
+			break
+		}
+	}
+	return ByteView{}, false
+}
+
+// getFromSiblings 在本节点是 key 的副本之一时，向其他副本查找已缓存的值，找到时写入 mainCache。
+// 查找只读取对方的缓存，对方未命中时返回的 ErrNotFound 不计入熔断器，也不重试。
+func (g *Group) getFromSiblings(ctx context.Context, key string, gen uint64) (ByteView, bool) {
+	owners, self := g.replicaOwners(key)
+	if !self {
+		return ByteView{}, false
+	}
+	seq := atomic.LoadUint64(&g.storeSeq)
+	for _, o := range owners {
+		if o == nil || !g.peerHealthy(o) {
+			continue
+		}
+		req := &pb.GetRequest{
+			Group:      g.name,
+			Key:        key,
+			Generation: gen,
+			CacheOnly:  true,
+		}
+		res := &pb.GetResponse{}
+		err := g.callPeer(ctx, o, func() error {
+			return o.Get(ctx, req, res)
+		})
+		if err != nil {
+			if ctx != nil && ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		value, err := g.parsePeerResponse(res, gen)
+		if err != nil {
+			continue
+		}
+		g.Stats.ReplicaHits.Add(1)
+		g.populateLoaded(key, value, seq)
+		return value, true
+	}
+	return ByteView{}, false
+}
+
+// lookupReplica 只在本地缓存中查找 key，用于应答其他副本的查找，处于宽限期的旧值不算命中
+func (g *Group) lookupReplica(key string) (ByteView, bool) {
+	value, _, ok := g.lookupCache(key)
+	if !ok || g.isStale(value) {
+		return ByteView{}, false
+	}
+	return value, true
+}
+
+// replicate 在后台把 key 的值推送给除本节点和 skip 以外的其余副本，失败只记录日志
+func (g *Group) replicate(key string, value ByteView, skip ProtoGetter) {
+	owners, _ := g.replicaOwners(key)
+	for _, o := range owners {
+		if o == nil || o == skip {
+			continue
+		}
+		r, ok := o.(Replicator)
+		if !ok {
+			continue
+		}
+		var expire int64
+		if !value.Expire().IsZero() {
+			expire = value.Expire().UnixNano()
+		}
+		v, encoding := g.compress(value.ByteSlice())
+		req := &pb.SetRequest{
+			Group:      g.name,
+			Key:        key,
+			Value:      v,
+			Expire:     expire,
+			Encoding:   encoding,
+			Tags:       value.tags,
+			Generation: value.gen,
+		}
+		go func(peer ProtoGetter) {
+			ctx, cancel := context.WithTimeout(context.Background(), replicateTimeout)
+			defer cancel()
+			err := g.callPeer(ctx, peer, func() error {
+				return r.Replicate(ctx, req)
+			})
+			if err == nil {
+				g.Stats.ReplicaPushes.Add(1)
+				return
+			}
+			g.Stats.ReplicaPushErrs.Add(1)
+			if logger := g.getLogger(); logger != nil {
+				logger.Warn().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"key":      key,
+						"category": "groupcache",
+					}).Printf("error replicating key to peer '%s'", peer.GetURL())
+			}
+		}(o)
+	}
+}
+
+// acceptReplica 把其他副本推送的值写入 mainCache，代数比本节点旧的副本被丢弃
+func (g *Group) acceptReplica(in *pb.SetRequest) error {
+	if in.GetKey() == "" {
+		return errors.New("empty replica key not allowed")
+	}
+	g.adoptGeneration(in.GetGeneration())
+	if in.GetGeneration() != 0 && in.GetGeneration() < g.generation() {
+		return errOlderGeneration
+	}
+	b, err := decompress(in.GetValue(), in.GetEncoding())
+	if err != nil {
+		return err
+	}
+	var expire time.Time
+	if in.GetExpire() != 0 {
+		expire = time.Unix(0, in.GetExpire())
+	}
+	g.localSet(in.GetKey(), b, expire, in.GetTags(), &g.mainCache)
+	return nil
+}
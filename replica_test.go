@@ -0,0 +1,176 @@
+package geecache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCluster 创建 n 个代表不同节点的组，所有键在哈希环上的顺序都是 0, 1, ..., n-1
+func newTestCluster(t *testing.T, n int, getter Getter, opts ...GroupOption) ([]*Group, []*nodePeer) {
+	t.Helper()
+	peers := make([]*nodePeer, n)
+	for i := range peers {
+		peers[i] = &nodePeer{url: fmt.Sprintf("node%d", i)}
+	}
+	groups := make([]*Group, n)
+	for i := range groups {
+		owners := make([]ProtoGetter, n)
+		for j, p := range peers {
+			if j != i {
+				owners[j] = p
+			}
+		}
+		name := fmt.Sprintf("%s_%d", strings.ReplaceAll(t.Name(), "/", "_"), i)
+		groups[i] = NewGroupWithOptions(name, getter, append([]GroupOption{WithPeerPicker(ringPicker{owners})}, opts...)...)
+		peers[i].g = groups[i]
+		t.Cleanup(func() { DeregisterGroup(name) })
+	}
+	return groups, peers
+}
+
+// waitFor 等待 cond 成立，用于等待后台推送副本
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplicationSingleLoader(t *testing.T) {
+	var loads int32
+	nodes, _ := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2), WithHotCache(false))
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	ctx := context.Background()
+
+	// 第二个副本未命中时从第一个副本获取，不调用 Getter
+	var s string
+	if err := b.Get(ctx, "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if err := c.Get(ctx, "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if loads != 1 {
+		t.Fatalf("getter called %d times, want 1", loads)
+	}
+	if _, ok := a.lookupReplica("k"); !ok {
+		t.Fatal("first replica did not cache the key")
+	}
+	if _, ok := b.lookupReplica("k"); !ok {
+		t.Fatal("second replica did not keep the key in mainCache")
+	}
+	if _, ok := c.lookupReplica("k"); ok {
+		t.Fatal("non-replica cached the key in mainCache")
+	}
+}
+
+func TestReplicationFailover(t *testing.T) {
+	var loads int32
+	nodes, peers := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2), WithHotCache(false))
+	b, c := nodes[1], nodes[2]
+	ctx := context.Background()
+
+	// 第一个副本宕机，由第二个副本加载并应答
+	peers[0].down.Store(true)
+	var s string
+	if err := c.Get(ctx, "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if loads != 1 || c.Stats.ReplicaFailovers.Get() != 1 {
+		t.Fatalf("loads = %d, failovers = %d", loads, c.Stats.ReplicaFailovers.Get())
+	}
+	if _, ok := b.lookupReplica("k"); !ok {
+		t.Fatal("second replica did not cache the key")
+	}
+}
+
+func TestReplicationSiblings(t *testing.T) {
+	var loads int32
+	nodes, peers := newTestCluster(t, 3, countingGetter(&loads), WithReplication(3), WithHotCache(false))
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	ctx := context.Background()
+
+	var s string
+	if err := a.Get(ctx, "k", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "replica pushes", func() bool { return a.Stats.ReplicaPushes.Get() == 2 })
+	if _, ok := c.lookupReplica("k"); !ok {
+		t.Fatal("key not pushed to the third replica")
+	}
+
+	// 第一个副本宕机，第二个副本丢失了该键，从第三个副本的缓存中取得
+	b.localRemove("k")
+	peers[0].down.Store(true)
+	if err := b.Get(ctx, "k", StringSink(&s)); err != nil || s != "v:k" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if loads != 1 || b.Stats.ReplicaHits.Get() != 1 {
+		t.Fatalf("loads = %d, replica hits = %d", loads, b.Stats.ReplicaHits.Get())
+	}
+}
+
+func TestReplicationSet(t *testing.T) {
+	var loads int32
+	nodes, _ := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2), WithHotCache(false))
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	ctx := context.Background()
+
+	// 非副本的 Set 交给第一个副本，由它推送给第二个副本
+	if err := c.Set(ctx, "s", []byte("x"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := a.lookupReplica("s"); !ok || v.String() != "x" {
+		t.Fatal("owner did not store the value")
+	}
+	waitFor(t, "replica push", func() bool {
+		v, ok := b.lookupReplica("s")
+		return ok && v.String() == "x"
+	})
+}
+
+func TestReplicationGetMany(t *testing.T) {
+	var loads int32
+	nodes, peers := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2), WithHotCache(false))
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	ctx := context.Background()
+
+	got := func(g *Group, keys ...string) []string {
+		var vals []string
+		g.GetMany(ctx, keys, func(key string, v ByteView, err error) {
+			if err != nil {
+				t.Fatalf("GetMany(%s) error = %v", key, err)
+			}
+			vals = append(vals, v.String())
+		})
+		return vals
+	}
+
+	// 与 Get 相同，第一个副本加载并推送给第二个副本
+	if vals := got(c, "m1", "m2"); strings.Join(vals, ",") != "v:m1,v:m2" {
+		t.Fatalf("GetMany = %v", vals)
+	}
+	if _, ok := a.lookupReplica("m1"); !ok {
+		t.Fatal("first replica did not cache the key")
+	}
+	waitFor(t, "replica push", func() bool {
+		_, ok := b.lookupReplica("m2")
+		return ok
+	})
+
+	// 第一个副本宕机时改由第二个副本应答
+	peers[0].down.Store(true)
+	if vals := got(c, "m1", "m3"); strings.Join(vals, ",") != "v:m1,v:m3" {
+		t.Fatalf("GetMany = %v", vals)
+	}
+	if loads != 3 {
+		t.Fatalf("getter called %d times, want 3", loads)
+	}
+}
@@ -5,6 +5,7 @@ package geecache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/CodingCaius/geecache/consistenthash"
 	pb "github.com/CodingCaius/geecache/geecachepb"
@@ -47,6 +48,9 @@ type server struct {
 	mu sync.Mutex // 互斥锁，用于保护 server 结构体的并发访问
 	consHash *consistenthash.Map // 一致性哈希，用于选择节点
 	clients map[string]*client // 用于存储 缓存节点的客户端,键是缓存节点的地址（格式为 ip:port），值是对应节点的客户端对象
+	snapshotDir string // 快照目录，非空时 Stop 会保存 mainCache 快照，Start 会从快照恢复
+	health *healthTracker // 各个远程节点的健康状态，不健康的节点暂时不会被 Pick
+	probeStop chan struct{} // 关闭时停止健康检查的探测
 }
 
 // NewServer 创建cache的svr 若addr为空 则使用defaultAddr
@@ -57,7 +61,7 @@ func NewServer(addr string) (*server, error) {
 	if !validPeerAddr(addr) {
 		return nil, fmt.Errorf("invalid addr %s, it should be x.x.x.x:port", addr)
 	}
-	return &server{addr: addr}, nil
+	return &server{addr: addr, health: newHealthTracker(HealthOptions{})}, nil
 }
 
 // Get 实现了 geecachepb.proto 文件中 GroupCache 接口的 Get 方法，用于处理 gRPC 请求
@@ -73,14 +77,137 @@ func (s *server) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, e
 	if g == nil {
 		return resp, fmt.Errorf("group not found")
 	}
-	view, err := g.Get(key)
+	// 请求方的代数更新说明本节点错过了 Purge，先跟上再应答
+	g.adoptGeneration(in.GetGeneration())
+	// 其他副本查找时只用本地缓存应答，避免副本之间互相加载
+	if in.GetCacheOnly() {
+		view, ok := g.lookupReplica(key)
+		if !ok {
+			return resp, rpcError(&ErrNotFound{Msg: fmt.Sprintf("key '%s' not cached on %s", key, s.addr)})
+		}
+		return newGetResponse(g, key, view), nil
+	}
+	var view ByteView
+	// ErrNotFound 和加载失败转换为不同的状态码，请求方据此决定是否在本地加载
+	if err := g.Get(ctx, key, ByteViewSink(&view)); err != nil {
+		return resp, rpcError(err)
+	}
+	return newGetResponse(g, key, view), nil
+}
+
+// Set 实现了 GroupCache 接口的 Set 方法，本节点作为所有者写入数据源并更新 mainCache
+func (s *server) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	resp := &pb.SetResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - Set (%s)/(%s)", s.addr, in.GetGroup(), in.GetKey())
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	return resp, rpcError(g.acceptSet(ctx, in))
+}
+
+// Remove 实现了 GroupCache 接口的 Remove 方法，从本节点删除该键
+func (s *server) Remove(ctx context.Context, in *pb.GetRequest) (*pb.RemoveResponse, error) {
+	resp := &pb.RemoveResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - Remove (%s)/(%s)", s.addr, in.GetGroup(), in.GetKey())
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	return resp, rpcError(g.acceptRemove(ctx, in.GetKey()))
+}
+
+// Ping 实现了 GroupCache 接口的 Ping 方法，应答其他节点的健康检查
+func (s *server) Ping(ctx context.Context, in *pb.PingRequest) (*pb.PingResponse, error) {
+	return &pb.PingResponse{}, nil
+}
+
+// GetMany 实现了 GroupCache 接口的 GetMany 方法，一次获取多个键，每个键的结果单独返回
+func (s *server) GetMany(ctx context.Context, in *pb.GetManyRequest) (*pb.GetManyResponse, error) {
+	group, keys := in.GetGroup(), in.GetKeys()
+	resp := &pb.GetManyResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - (%s)/(%d keys)", s.addr, group, len(keys))
+	g := GetGroup(group)
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	g.adoptGeneration(in.GetGeneration())
+	resp.Generation = g.generation()
+	resp.Results = make([]*pb.GetManyResult, 0, len(keys))
+	g.GetMany(ctx, keys, func(key string, view ByteView, err error) {
+		r := &pb.GetManyResult{}
+		if err != nil {
+			r.Error = err.Error()
+			r.NotFound = errors.Is(err, &ErrNotFound{})
+		} else {
+			r.Value = newGetResponse(g, key, view)
+		}
+		resp.Results = append(resp.Results, r)
+	})
+	return resp, nil
+}
+
+// Invalidate 实现了 GroupCache 接口的 Invalidate 方法，清除本节点上带有指定标签或以指定前缀开头的条目
+func (s *server) Invalidate(ctx context.Context, in *pb.InvalidateRequest) (*pb.InvalidateResponse, error) {
+	resp := &pb.InvalidateResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - Invalidate (%s)", s.addr, in.GetGroup())
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	n, err := g.localInvalidate(in)
 	if err != nil {
-		return resp, err
+		return resp, rpcError(err)
 	}
-	resp.Value = view.ByteSlice()
+	resp.Removed = int64(n)
 	return resp, nil
 }
 
+// Purge 实现了 GroupCache 接口的 Purge 方法，切换到请求中的组代数
+func (s *server) Purge(ctx context.Context, in *pb.PurgeRequest) (*pb.PurgeResponse, error) {
+	resp := &pb.PurgeResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - Purge (%s)/(%d)", s.addr, in.GetGroup(), in.GetGeneration())
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	g.adoptGeneration(in.GetGeneration())
+	resp.Generation = g.generation()
+	return resp, nil
+}
+
+// Replicate 实现了 GroupCache 接口的 Replicate 方法，把请求中的副本写入本节点的 mainCache
+func (s *server) Replicate(ctx context.Context, in *pb.SetRequest) (*pb.ReplicateResponse, error) {
+	resp := &pb.ReplicateResponse{}
+
+	log.Printf("[geecache_svr %s] Recv RPC Request - Replicate (%s)/(%s)", s.addr, in.GetGroup(), in.GetKey())
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	return resp, rpcError(g.acceptReplica(in))
+}
+
+// newGetResponse 根据组 g 中 key 的数据构造 GetResponse
+func newGetResponse(g *Group, key string, view ByteView) *pb.GetResponse {
+	resp := &pb.GetResponse{}
+	// 按组的配置压缩后再发送，请求方根据 Encoding 解压
+	resp.Value, resp.Encoding = g.compress(view.ByteSlice())
+	if !view.Expire().IsZero() {
+		resp.Expire = view.Expire().UnixNano()
+	}
+	// 报告该键在本节点（所有者）上的访问频率，供请求方决定是否加入 hotCache
+	resp.MinuteQps = g.KeyQPS(key)
+	resp.Tags = view.tags
+	resp.Generation = g.generation()
+	return resp
+}
+
 // Start 启动缓存服务，包括监听指定地址的 TCP 连接和注册服务至 etcd
 func (s *server) Start() error {
 	// 获取服务器状态的互斥锁，以确保在对状态进行更改时不会被其他 goroutine 干扰
@@ -99,14 +226,18 @@ func (s *server) Start() error {
 	//    以及etcd的Host即可获取对应服务IP 无需写死至client代码中
 	// ----------------------------------------------
 
-	s.status = true
-	s.stopSignal = make(chan error)
-
 	port := strings.Split(s.addr, ":")[1]
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
+		s.mu.Unlock()
 		return fmt.Errorf("failed to listen: %v", err)
 	}
+
+	s.status = true
+	s.stopSignal = make(chan error)
+	s.probeStop = make(chan struct{})
+	// 定期探测各个远程节点，摘除和恢复不健康的节点
+	go s.probePeers(s.probeStop)
 	// 创建一个新的 gRPC 服务器并将缓存服务注册到该服务器上
 	grpcServer := grpc.NewServer()
 	pb.RegisterGeeCacheServer(grpcServer, s)
@@ -130,8 +261,17 @@ func (s *server) Start() error {
 		log.Printf("[%s] Revoke service and close tcp socket ok.", s.addr)
 	}()
 
+	snapshotDir := s.snapshotDir
 	s.mu.Unlock()
 
+	// 从快照中恢复各个组的 mainCache，实现热启动
+	// 恢复时会调用 PickPeer 跳过不再属于本节点的键，因此需要在释放锁之后进行
+	if snapshotDir != "" {
+		if err := RestoreAll(snapshotDir); err != nil {
+			log.Printf("[%s] restore snapshot failed: %v", s.addr, err)
+		}
+	}
+
 	// 启动 gRPC 服务器开始监听 gRPC 请求。它是一个阻塞操作，会一直运行直到服务停止或发生错误。
 	// 当有新的 gRPC 请求到达时，它将调用之前注册的 gRPC 处理函数来处理请求。
 	if err := grpcServer.Serve(lis); s.status && err != nil {
@@ -159,8 +299,12 @@ func (s *server) SetPeers(peersAddr ...string) {
 			panic(fmt.Sprintf("[peer %s] invalid address format, it should be x.x.x.x:port", peerAddr))
 		}
 		service := fmt.Sprintf("geecache/%s", peerAddr)
-		s.clients[peerAddr] = NewClient(service)
+		c := NewClient(service)
+		c.addr, c.health = peerAddr, s.health
+		s.clients[peerAddr] = c
 	}
+	// 保留仍在集群中的节点的健康状态
+	s.health.retain(peersAddr)
 }
 
 // Pick 根据键选择合适的节点来获取缓存数据
@@ -169,7 +313,17 @@ func (s *server) PickPeer(key string) (ProtoGetter, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// 尚未调用 SetPeers 或已经 Stop 时没有哈希环，所有键都由本节点负责
+	if s.consHash == nil {
+		return nil, false
+	}
 	peerAddr := s.consHash.Get(key)
+	// 所有者被健康检查摘除时，改由哈希环上的下一个健康节点负责
+	if peerAddr != s.addr && !s.health.healthy(peerAddr) {
+		if owners := s.healthyOwners(key, 1); len(owners) > 0 {
+			peerAddr = owners[0]
+		}
+	}
 	// 如果选的节点是自身，无需通过网络通信来获取缓存
 	if peerAddr == s.addr {
 		log.Printf("ooh! pick myself, I am %s\n", s.addr)
@@ -179,6 +333,50 @@ func (s *server) PickPeer(key string) (ProtoGetter, bool) {
 	return s.clients[peerAddr], true
 }
 
+// PickOwners 按哈希环上的顺序返回 key 的前 n 个所有者，所有者是本节点时对应的元素为 nil。
+// 被健康检查摘除的节点会被跳过。
+func (s *server) PickOwners(key string, n int) []ProtoGetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 没有哈希环时本节点是唯一的所有者
+	if s.consHash == nil {
+		return []ProtoGetter{nil}
+	}
+	addrs := s.healthyOwners(key, n)
+	owners := make([]ProtoGetter, len(addrs))
+	for i, addr := range addrs {
+		if addr != s.addr {
+			owners[i] = s.clients[addr]
+		}
+	}
+	return owners
+}
+
+// GetAll 返回除本节点以外的所有节点
+func (s *server) GetAll() []ProtoGetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peers := make([]ProtoGetter, 0, len(s.clients))
+	for addr, c := range s.clients {
+		if addr != s.addr {
+			peers = append(peers, c)
+		}
+	}
+	return peers
+}
+
+// SetSnapshotDir 设置快照目录
+// 设置后，Stop 会将所有组的 mainCache 快照保存到该目录，Start 会在开始服务前从中恢复
+// 恢复时会跳过已不再哈希到本节点的键，因此应当在 SetPeers 之后再调用 Start；
+// 在此之前调用 Start 时本节点被当作唯一的节点，快照中的键全部恢复
+func (s *server) SetSnapshotDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshotDir = dir
+}
+
 // Stop 停止server运行 如果server没有运行 这将是一个no-op
 func (s *server) Stop() {
 	s.mu.Lock()
@@ -186,6 +384,19 @@ func (s *server) Stop() {
 		s.mu.Unlock()
 		return
 	}
+	// 将 write-behind 队列中尚未写入的操作写入数据源
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := FlushAll(ctx); err != nil {
+		log.Printf("[%s] flush write-behind queues failed: %v", s.addr, err)
+	}
+	cancel()
+	// 保存 mainCache 快照，供下次启动时恢复
+	if s.snapshotDir != "" {
+		if err := SnapshotAll(s.snapshotDir); err != nil {
+			log.Printf("[%s] save snapshot failed: %v", s.addr, err)
+		}
+	}
+	close(s.probeStop) // 停止健康检查的探测
 	s.stopSignal <- nil // 发送停止 keep alive 信号
 	s.status = false // 设置 server 运行状态为 stop
 	s.clients = nil
@@ -55,6 +55,57 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	return c.val, c.err
 }
 
+// DoMany 与 Do 相同，但一次处理多个 key。
+// 当前没有进行中调用的 key 会被合并为一次 fn 调用，fn 返回的 vals 和 errs 必须与传入的 keys 一一对应；
+// 已有进行中调用的 key 会等待该调用完成，并共享其结果。
+// 返回的 vals 和 errs 与 keys 一一对应。
+func (g *Group) DoMany(keys []string, fn func(keys []string) ([]interface{}, []error)) ([]interface{}, []error) {
+	calls := make([]*call, len(keys))
+	var own []string
+	var ownCalls []*call
+
+	// 在一次加锁中为所有空闲的 key 登记调用，保证它们被同一次 fn 调用处理
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	for i, key := range keys {
+		if c, ok := g.m[key]; ok {
+			calls[i] = c
+			continue
+		}
+		c := new(call)
+		c.wg.Add(1)
+		g.m[key] = c
+		calls[i] = c
+		own = append(own, key)
+		ownCalls = append(ownCalls, c)
+	}
+	g.mu.Unlock()
+
+	if len(own) > 0 {
+		vals, errs := fn(own)
+		for i, c := range ownCalls {
+			c.val, c.err = vals[i], errs[i]
+			c.wg.Done()
+		}
+		g.mu.Lock()
+		for _, key := range own {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+	}
+
+	// 自己负责的调用已经完成，这里只会等待其他调用者发起的调用
+	vals := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	for i, c := range calls {
+		c.wg.Wait()
+		vals[i], errs[i] = c.val, c.err
+	}
+	return vals, errs
+}
+
 // sync.WaitGroup 是 Go 语言标准库中的一个同步原语，用于等待一组 goroutine 完成其任务。它通常用于等待一组并发操作完成后再继续执行其他操作。
 
 // WaitGroup 主要有三个方法：
@@ -54,6 +54,35 @@ type Sink interface {
 	view() (ByteView, error)
 }
 
+// SetTags 为 Getter 正在加载的值附加标签，应在 Getter.Get 中对传入的 dest 调用，
+// 与 SetString 等方法的先后顺序无关。
+// 之后可以通过 Group.RemoveByTag 使所有节点上带有该标签的条目失效。
+func SetTags(dest Sink, tags ...string) error {
+	ts, ok := dest.(interface{ setTags(tags []string) })
+	if !ok {
+		return errors.New("sink does not support tags")
+	}
+	ts.setTags(append([]string(nil), tags...))
+	return nil
+}
+
+// sinkTags 保存通过 SetTags 附加的标签，嵌入到各个 Sink 实现中
+type sinkTags struct {
+	tags []string
+}
+
+func (t *sinkTags) setTags(tags []string) {
+	t.tags = tags
+}
+
+// withTags 为 v 附上标签，没有附加标签时保留 v 原有的标签
+func (t *sinkTags) withTags(v ByteView) ByteView {
+	if t.tags != nil {
+		v.tags = t.tags
+	}
+	return v
+}
+
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
 	copy(c, b)
@@ -95,13 +124,14 @@ type stringSink struct {
 	// 为了支持在 SetString 方法中修改传入的字符串值，使用了字符串指针。
 	sp *string // 字符串指针，指向传入的需保存的字符串的值
 	v  ByteView
+	sinkTags
 	// TODO(bradfitz): track whether any Sets were called.
 }
 
 // view 返回一个 ByteView，表示当前 stringSink 中保存的数据的视图。
 func (s *stringSink) view() (ByteView, error) {
 	// TODO(bradfitz): return an error if no Set was called
-	return s.v, nil
+	return s.withTags(s.v), nil
 }
 
 // 将传入的字符串值 v 设置到 stringSink 中, 同时更新相关的状态信息
@@ -144,19 +174,26 @@ func ByteViewSink(dst *ByteView) Sink {
 // 实现了 Sink 接口，主要用于存储 字节数字 类型数据
 type byteViewSink struct {
 	dst *ByteView
+	sinkTags
 	/*
 		虽然在某些情况下强调 set* 方法只能调用一次，但在实际使用中，如果有多个处理器或者程序中的多个函数需要使用同一个 Sink，并且多次调用 set* 方法并不会引起问题，那么就不必将多次调用看作是错误。
 	*/
 }
 
 // 提供了一种快速设置 byteViewSink 目标字段的方式，直接使用传入的 ByteView 值，而无需额外的处理或转换。
+// setTags 同时为已经设置的值附上标签，调用方直接读取 dst 时也能看到 Getter 附加的标签
+func (s *byteViewSink) setTags(tags []string) {
+	s.sinkTags.setTags(tags)
+	s.dst.tags = tags
+}
+
 func (s *byteViewSink) setView(v ByteView) error {
 	*s.dst = v
 	return nil
 }
 
 func (s *byteViewSink) view() (ByteView, error) {
-	return *s.dst, nil
+	return s.withTags(*s.dst), nil
 }
 
 func (s *byteViewSink) SetProto(m proto.Message, e time.Time) error {
@@ -164,7 +201,7 @@ func (s *byteViewSink) SetProto(m proto.Message, e time.Time) error {
 	if err != nil {
 		return err
 	}
-	*s.dst = ByteView{b: b, e: e}
+	*s.dst = s.withTags(ByteView{b: b, e: e})
 	return nil
 }
 
@@ -172,12 +209,12 @@ func (s *byteViewSink) SetBytes(b []byte, e time.Time) error {
 	// 字节数组的赋值操作实际上是复制了引用，而不是字节数组的内容
 	// 由于 cloneBytes(b) 返回的是 b 的副本，即一个新的字节切片，
 	// 因此在后续的操作中，外部修改原始字节切片 b 不会影响 *s.dst。
-	*s.dst = ByteView{b: cloneBytes(b), e: e}
+	*s.dst = s.withTags(ByteView{b: cloneBytes(b), e: e})
 	return nil
 }
 
 func (s *byteViewSink) SetString(v string, e time.Time) error {
-	*s.dst = ByteView{s: v, e: e}
+	*s.dst = s.withTags(ByteView{s: v, e: e})
 	return nil
 }
 
@@ -195,10 +232,11 @@ type protoSink struct {
 	typ string
 
 	v ByteView // encoded
+	sinkTags
 }
 
 func (s *protoSink) view() (ByteView, error) {
-	return s.v, nil
+	return s.withTags(s.v), nil
 }
 
 func (s *protoSink) SetBytes(b []byte, e time.Time) error {
@@ -270,10 +308,11 @@ func AllocatingByteSliceSink(dst *[]byte) Sink {
 type allocBytesSink struct {
 	dst *[]byte
 	v   ByteView
+	sinkTags
 }
 
 func (s *allocBytesSink) view() (ByteView, error) {
-	return s.v, nil
+	return s.withTags(s.v), nil
 }
 
 // setView 根据传入的 ByteView，将其中的数据复制到 *s.dst 中，并确保在这个过程中对原始数据的修改不会影响到 *s.dst。
@@ -335,10 +374,11 @@ func TruncatingByteSliceSink(dst *[]byte) Sink {
 type truncBytesSink struct {
 	dst *[]byte
 	v   ByteView
+	sinkTags
 }
 
 func (s *truncBytesSink) view() (ByteView, error) {
-	return s.v, nil
+	return s.withTags(s.v), nil
 }
 
 func (s *truncBytesSink) SetProto(m proto.Message, e time.Time) error {
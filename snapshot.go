@@ -0,0 +1,323 @@
+// 缓存快照与热启动
+// 将 mainCache 中的键、值和过期时间序列化为带版本号和校验和的格式，
+// 节点重启后可以从快照中恢复，避免冷启动时大量请求直接打到数据源。
+
+package geecache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 快照文件格式（整数均为变长编码，除非特别说明）：
+//
+//	magic(8) | version | len(group) | group
+//	{ 1 | len(key) | key | len(value) | value | expire | ntags | { len(tag) | tag } ... } ...
+//	0 | count | crc32(4, 大端序，覆盖之前的所有字节)
+//
+// expire 为 UnixNano，0 表示永不过期。版本 1 的记录没有标签部分。
+const (
+	snapshotMagic   = "GEESNAP\x00"
+	snapshotVersion = 2
+
+	snapshotSuffix = ".snap"
+
+	// 单个键或值的最大长度，用于防止损坏的快照导致过大的内存分配
+	maxSnapshotField = 1 << 30
+)
+
+// snapshotRecord 是快照中的一条记录
+type snapshotRecord struct {
+	key   string
+	value []byte
+	e     time.Time
+	tags  []string
+}
+
+// Snapshot 将 mainCache 中未过期的条目写入 w。
+// 写入期间缓存仍可正常读写，快照反映的是开始遍历时的内容。
+func (g *Group) Snapshot(w io.Writer) error {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+
+	var buf [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf[:], v)
+		bw.Write(buf[:n])
+	}
+	putBytes := func(b []byte) {
+		putUvarint(uint64(len(b)))
+		bw.Write(b)
+	}
+
+	bw.WriteString(snapshotMagic)
+	putUvarint(snapshotVersion)
+	putBytes([]byte(g.name))
+
+	var count uint64
+	now := NowFunc()
+	g.mainCache.each(func(key string, value ByteView) bool {
+		e := value.Expire()
+		if (!e.IsZero() && e.Before(now)) || g.purged(value) {
+			return true
+		}
+		var expire int64
+		if !e.IsZero() {
+			expire = e.UnixNano()
+		}
+		// 快照中始终保存解压后的数据，恢复时按当前的压缩配置重新压缩
+		value, err := decodeView(value)
+		if err != nil {
+			return true
+		}
+		bw.WriteByte(1)
+		putBytes([]byte(key))
+		putBytes(value.ByteSlice())
+		n := binary.PutVarint(buf[:], expire)
+		bw.Write(buf[:n])
+		putUvarint(uint64(len(value.tags)))
+		for _, tag := range value.tags {
+			putBytes([]byte(tag))
+		}
+		count++
+		return true
+	})
+	bw.WriteByte(0)
+	putUvarint(count)
+	// 校验和只覆盖之前的内容，需要先把缓冲区刷到 crc 中
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	if _, err := w.Write(sum[:]); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore 从 r 中读取 Snapshot 写入的快照，并将条目填充到 mainCache 中。
+// 快照会先被完整读取并校验，校验失败时不会修改缓存。
+// 已过期的条目，以及按照当前的一致性哈希已不再属于本节点的键会被跳过；开启复制时保留本节点是其副本之一的键。
+// 返回实际恢复的条目数。
+func (g *Group) Restore(r io.Reader) (int, error) {
+	g.peersOnce.Do(g.initPeers)
+
+	records, err := readSnapshot(bufio.NewReader(r), g.name)
+	if err != nil {
+		return 0, err
+	}
+
+	now := NowFunc()
+	restored := 0
+	for _, rec := range records {
+		if !rec.e.IsZero() && rec.e.Before(now) {
+			continue
+		}
+		// 开启复制时，本节点是任一副本的键都保存在 mainCache 中
+		if owners, self := g.replicaOwners(rec.key); owners != nil {
+			if !self {
+				continue
+			}
+		} else if _, remote := g.peers.PickPeer(rec.key); remote {
+			continue
+		}
+		g.localSet(rec.key, rec.value, rec.e, rec.tags, &g.mainCache)
+		restored++
+	}
+	return restored, nil
+}
+
+// crcReader 在读取的同时计算已消费字节的校验和
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readSnapshot 解析并校验一个快照
+func readSnapshot(br *bufio.Reader, group string) ([]snapshotRecord, error) {
+	r := &crcReader{r: br, crc: crc32.NewIEEE()}
+
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxSnapshotField {
+			return nil, fmt.Errorf("field too large: %d bytes", n)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, errors.New("snapshot: bad magic")
+	}
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: read version: %w", err)
+	}
+	if version != 1 && version != snapshotVersion {
+		return nil, fmt.Errorf("snapshot: unsupported version %d", version)
+	}
+	name, err := readBytes()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: read group: %w", err)
+	}
+	if string(name) != group {
+		return nil, fmt.Errorf("snapshot: belongs to group %q, not %q", name, group)
+	}
+
+	var records []snapshotRecord
+	for {
+		flag, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: truncated: %w", err)
+		}
+		if flag == 0 {
+			break
+		}
+		key, err := readBytes()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: read key: %w", err)
+		}
+		value, err := readBytes()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: read value: %w", err)
+		}
+		expire, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: read expire: %w", err)
+		}
+		rec := snapshotRecord{key: string(key), value: value}
+		if expire != 0 {
+			rec.e = time.Unix(0, expire)
+		}
+		if version >= 2 {
+			ntags, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot: read tags: %w", err)
+			}
+			for i := uint64(0); i < ntags; i++ {
+				tag, err := readBytes()
+				if err != nil {
+					return nil, fmt.Errorf("snapshot: read tags: %w", err)
+				}
+				rec.tags = append(rec.tags, string(tag))
+			}
+		}
+		records = append(records, rec)
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: read count: %w", err)
+	}
+	if count != uint64(len(records)) {
+		return nil, fmt.Errorf("snapshot: expected %d records, got %d", count, len(records))
+	}
+
+	// 校验和本身不计入 crc，直接从底层读取
+	var sum [4]byte
+	if _, err := io.ReadFull(br, sum[:]); err != nil {
+		return nil, fmt.Errorf("snapshot: read checksum: %w", err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != r.crc.Sum32() {
+		return nil, errors.New("snapshot: checksum mismatch")
+	}
+	return records, nil
+}
+
+// SnapshotFile 将快照写入 path。
+// 快照先写入同目录下的临时文件，成功后再重命名，避免留下不完整的快照。
+func (g *Group) SnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := g.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RestoreFile 从 path 中恢复快照，返回实际恢复的条目数
+func (g *Group) RestoreFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// snapshotPath 返回组在 dir 目录下的快照文件路径
+func snapshotPath(dir, group string) string {
+	return filepath.Join(dir, url.PathEscape(group)+snapshotSuffix)
+}
+
+// SnapshotAll 将所有已注册的组的 mainCache 分别快照到 dir 目录下，每个组一个文件
+func SnapshotAll(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var firstErr error
+	for _, g := range allGroups() {
+		if err := g.SnapshotFile(snapshotPath(dir, g.name)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("snapshot group %s: %w", g.name, err)
+		}
+	}
+	return firstErr
+}
+
+// RestoreAll 从 dir 目录中为所有已注册的组恢复快照，没有快照文件的组会被跳过
+func RestoreAll(dir string) error {
+	var firstErr error
+	for _, g := range allGroups() {
+		_, err := g.RestoreFile(snapshotPath(dir, g.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("restore group %s: %w", g.name, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,89 @@
+package geecache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithPeerPicker(fixedPicker{}))
+	ctx := context.Background()
+	var s string
+	for _, k := range []string{"a", "b", "c"} {
+		if err := g.Get(ctx, k, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.localSet("expired", []byte("x"), time.Now().Add(-time.Second), nil, &g.mainCache)
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		g.localRemove(k)
+	}
+
+	// 校验失败时不修改缓存
+	bad := append([]byte(nil), buf.Bytes()...)
+	bad[len(bad)-6] ^= 1
+	if _, err := g.Restore(bytes.NewReader(bad)); err == nil {
+		t.Fatal("corrupt snapshot accepted")
+	}
+	if n, err := g.Restore(bytes.NewReader(buf.Bytes())); err != nil || n != 3 {
+		t.Fatalf("Restore = %d, %v", n, err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := g.Get(ctx, k, StringSink(&s)); err != nil || s != "v:"+k {
+			t.Fatalf("Get(%s) = %q, %v", k, s, err)
+		}
+	}
+	if loads != 3 {
+		t.Fatalf("getter called %d times after restore, want 3", loads)
+	}
+}
+
+// 开启复制时，副本恢复自己持有的键，其他节点跳过
+func TestSnapshotRestoreReplicas(t *testing.T) {
+	var loads int32
+	nodes, _ := newTestCluster(t, 3, countingGetter(&loads), WithReplication(2), WithHotCache(false))
+	for i, want := range []int{1, 1, 0} {
+		g := nodes[i]
+		g.localSet("k", []byte("v"), time.Time{}, nil, &g.mainCache)
+		var buf bytes.Buffer
+		if err := g.Snapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		g.localRemove("k")
+		if n, err := g.Restore(&buf); err != nil || n != want {
+			t.Fatalf("node %d: Restore = %d, %v, want %d", i, n, err, want)
+		}
+	}
+}
+
+// 未调用 SetPeers 的 server 没有哈希环，Start 恢复快照时本节点负责所有键
+func TestSnapshotRestoreWithoutPeers(t *testing.T) {
+	s, err := NewServer("127.0.0.1:7001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var loads int32
+	for _, opts := range [][]GroupOption{nil, {WithReplication(2)}} {
+		g := newTestGroup(t, countingGetter(&loads), append(opts, WithPeerPicker(s))...)
+		g.localSet("k", []byte("v"), time.Time{}, nil, &g.mainCache)
+		if err := g.SnapshotFile(snapshotPath(dir, g.name)); err != nil {
+			t.Fatal(err)
+		}
+		g.localRemove("k")
+		if err := RestoreAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := g.mainCache.peek("k"); !ok {
+			t.Fatalf("replicas = %d: key not restored", g.replicas)
+		}
+		DeregisterGroup(g.name)
+	}
+}
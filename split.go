@@ -0,0 +1,151 @@
+// mainCache 与 hotCache 之间的空间划分
+// 缓存总量超出 cacheBytes 时需要从两者中选择一个淘汰。
+// 默认沿用固定比例的规则；自适应策略则根据两者每字节节省的延迟动态调整 hotCache 的目标占比。
+
+package geecache
+
+import (
+	"sync"
+	"time"
+)
+
+// EvictionPolicy 决定缓存超出上限时从 mainCache 还是 hotCache 中淘汰
+type EvictionPolicy int
+
+const (
+	// FixedRatioEviction 在 hotCache 超过 mainCache 的固定比例（默认 1/8）时淘汰 hotCache，否则淘汰 mainCache。
+	FixedRatioEviction EvictionPolicy = iota
+
+	// AdaptiveEviction 根据测量结果调整 hotCache 的目标占比：
+	// mainCache 命中节省一次本地加载，hotCache 命中节省一次远程获取，
+	// 哪一方每字节节省的延迟更多，预算就向哪一方倾斜。
+	AdaptiveEviction
+)
+
+const (
+	defaultHotRatio = 1.0 / 8
+
+	// 自适应策略的调整间隔、每次调整的步长以及 hotCache 占比的上下限
+	splitAdjustInterval = time.Second
+	splitStep           = 0.02
+	minHotShare         = 0.01
+	maxHotShare         = 0.9
+
+	// 延迟的指数移动平均系数
+	costAlpha = 0.1
+)
+
+// splitTuner 保存划分策略的配置以及自适应策略的测量数据
+type splitTuner struct {
+	mu sync.Mutex
+
+	policy EvictionPolicy
+
+	// hotRatio 是固定比例策略下 hotCache 相对 mainCache 的比例
+	hotRatio float64
+
+	// hotShare 是 hotCache 在 mainCache 与 hotCache 总字节数中的目标占比
+	hotShare float64
+
+	// localCost 和 peerCost 分别是本地加载和远程获取的平均耗时（纳秒）
+	localCost, peerCost float64
+
+	lastMainHits, lastHotHits int64
+	lastAdjust                time.Time
+}
+
+// setEvictionPolicy 设置 mainCache 与 hotCache 之间的淘汰策略，默认为 FixedRatioEviction。
+// hotRatio 是固定比例策略下 hotCache 相对 mainCache 的比例，为零时使用默认值 1/8；
+// 对自适应策略而言，它决定了 hotCache 的初始目标占比。
+func (g *Group) setEvictionPolicy(policy EvictionPolicy, hotRatio float64) {
+	if hotRatio <= 0 {
+		hotRatio = defaultHotRatio
+	}
+	g.split.mu.Lock()
+	defer g.split.mu.Unlock()
+	g.split.policy = policy
+	g.split.hotRatio = hotRatio
+	g.split.hotShare = hotRatio / (1 + hotRatio)
+	g.Stats.HotCacheShare.Store(int64(g.split.hotShare * 1000))
+}
+
+// observeLocalLoad 记录一次本地加载的耗时
+func (g *Group) observeLocalLoad(d time.Duration) {
+	g.split.mu.Lock()
+	g.split.localCost = ewma(g.split.localCost, float64(d))
+	g.split.mu.Unlock()
+}
+
+// observePeerLoad 记录一次远程获取的耗时
+func (g *Group) observePeerLoad(d time.Duration) {
+	g.split.mu.Lock()
+	g.split.peerCost = ewma(g.split.peerCost, float64(d))
+	g.split.mu.Unlock()
+}
+
+// chooseVictim 根据当前策略选择要淘汰的缓存
+func (g *Group) chooseVictim(mainBytes, hotBytes int64) *cache {
+	t := &g.split
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.policy != AdaptiveEviction {
+		// TODO(bradfitz): this is good-enough-for-now logic.
+		// It should be something based on measurements and/or
+		// respecting the costs of different resources.
+		// 固定比例策略，优先选择要淘汰的缓存是 mainCache 还是 hotCache，取决于它们的字节数比例
+		if float64(hotBytes) > float64(mainBytes)*t.hotRatio {
+			return &g.hotCache
+		}
+		return &g.mainCache
+	}
+
+	g.adjustSplitLocked(mainBytes, hotBytes)
+	if float64(hotBytes) > float64(mainBytes+hotBytes)*t.hotShare {
+		return &g.hotCache
+	}
+	return &g.mainCache
+}
+
+// adjustSplitLocked 每隔 splitAdjustInterval 比较两个缓存每字节节省的延迟，并移动 hotCache 的目标占比
+func (g *Group) adjustSplitLocked(mainBytes, hotBytes int64) {
+	t := &g.split
+	now := NowFunc()
+	if now.Sub(t.lastAdjust) < splitAdjustInterval {
+		return
+	}
+	t.lastAdjust = now
+
+	mainHits := g.mainCache.hits()
+	hotHits := g.hotCache.hits()
+	deltaMain := mainHits - t.lastMainHits
+	deltaHot := hotHits - t.lastHotHits
+	t.lastMainHits, t.lastHotHits = mainHits, hotHits
+
+	// 还没有测量到任何一方的耗时时，无法比较
+	if t.localCost == 0 || t.peerCost == 0 || mainBytes == 0 || hotBytes == 0 {
+		return
+	}
+	mainValue := float64(deltaMain) * t.localCost / float64(mainBytes)
+	hotValue := float64(deltaHot) * t.peerCost / float64(hotBytes)
+	switch {
+	case hotValue > mainValue:
+		t.hotShare += splitStep
+	case hotValue < mainValue:
+		t.hotShare -= splitStep
+	}
+	if t.hotShare < minHotShare {
+		t.hotShare = minHotShare
+	}
+	if t.hotShare > maxHotShare {
+		t.hotShare = maxHotShare
+	}
+	g.Stats.HotCacheShare.Store(int64(t.hotShare * 1000))
+}
+
+func ewma(old, sample float64) float64 {
+	if old == 0 {
+		return sample
+	}
+	return old + costAlpha*(sample-old)
+}
@@ -0,0 +1,70 @@
+package geecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveSplit(t *testing.T) {
+	now := time.Now()
+	NowFunc = func() time.Time { return now }
+	defer func() { NowFunc = time.Now }()
+
+	var loads int32
+	g := newTestGroup(t, countingGetter(&loads), WithEvictionPolicy(AdaptiveEviction))
+	if share := g.Stats.HotCacheShare.Get(); share != 111 {
+		t.Fatalf("initial hot share = %d, want 111", share)
+	}
+
+	// 远程获取比本地加载慢得多，两个缓存大小相同且 hotCache 命中更多，预算向 hotCache 倾斜
+	g.observeLocalLoad(time.Millisecond)
+	g.observePeerLoad(10 * time.Millisecond)
+	g.populateCache("m", ByteView{s: "value"}, &g.mainCache)
+	g.populateCache("h", ByteView{s: "value"}, &g.hotCache)
+	hit := func() {
+		g.mainCache.get("m")
+		for i := 0; i < 3; i++ {
+			g.hotCache.get("h")
+		}
+	}
+	adjust := func() {
+		g.split.mu.Lock()
+		g.adjustSplitLocked(g.mainCache.bytes(), g.hotCache.bytes())
+		g.split.mu.Unlock()
+	}
+
+	hit()
+	adjust()
+	share := g.Stats.HotCacheShare.Get()
+	if share <= 111 {
+		t.Fatalf("hot share = %d, want above 111", share)
+	}
+
+	// 调整间隔之内不再调整
+	hit()
+	adjust()
+	if got := g.Stats.HotCacheShare.Get(); got != share {
+		t.Fatalf("hot share changed within the interval: %d -> %d", share, got)
+	}
+
+	now = now.Add(splitAdjustInterval)
+	adjust()
+	if got := g.Stats.HotCacheShare.Get(); got <= share {
+		t.Fatalf("hot share = %d, want above %d", got, share)
+	}
+}
+
+// WithEvictionPolicy 和 WithHotCacheRatio 的先后顺序不影响结果
+func TestEvictionPolicyOptions(t *testing.T) {
+	var loads int32
+	for _, opts := range [][]GroupOption{
+		{WithEvictionPolicy(AdaptiveEviction), WithHotCacheRatio(0.25)},
+		{WithHotCacheRatio(0.25), WithEvictionPolicy(AdaptiveEviction)},
+	} {
+		g := NewGroupWithOptions(t.Name(), countingGetter(&loads), opts...)
+		DeregisterGroup(t.Name())
+		if g.split.policy != AdaptiveEviction || g.split.hotRatio != 0.25 || g.Stats.HotCacheShare.Get() != 200 {
+			t.Fatalf("policy = %v, ratio = %v, share = %d", g.split.policy, g.split.hotRatio, g.Stats.HotCacheShare.Get())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock 通过 NowFunc 为测试提供可以手动推进的时间
+type fakeClock struct{ ns atomic.Int64 }
+
+func newFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{}
+	c.ns.Store(time.Now().UnixNano())
+	NowFunc = c.now
+	t.Cleanup(func() { NowFunc = time.Now })
+	return c
+}
+
+func (c *fakeClock) now() time.Time          { return time.Unix(0, c.ns.Load()) }
+func (c *fakeClock) advance(d time.Duration) { c.ns.Add(int64(d)) }
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := newFakeClock(t)
+	var calls int32
+	var fail atomic.Bool
+	g := newTestGroup(t, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		n := atomic.AddInt32(&calls, 1)
+		if fail.Load() {
+			return errors.New("boom")
+		}
+		return dest.SetString(strconv.Itoa(int(n)), clock.now().Add(time.Second))
+	}), WithPeerPicker(fixedPicker{}), WithStaleGrace(time.Minute))
+	ctx := context.Background()
+	get := func() string {
+		var s string
+		if err := g.Get(ctx, "k", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if s := get(); s != "1" {
+		t.Fatalf("Get = %q, want 1", s)
+	}
+
+	// 宽限期内立即返回旧值，刷新失败时保留旧值
+	clock.advance(2 * time.Second)
+	fail.Store(true)
+	if s := get(); s != "1" {
+		t.Fatalf("Get = %q, want the stale value", s)
+	}
+	waitFor(t, "failed refresh", func() bool { return g.Stats.StaleRefreshErrs.Get() == 1 })
+	waitFor(t, "refresh done", func() bool {
+		_, running := g.refreshing.Load("k")
+		return !running
+	})
+	fail.Store(false)
+	if s := get(); s != "1" {
+		t.Fatalf("Get = %q, want the stale value kept", s)
+	}
+	waitFor(t, "refreshed value", func() bool {
+		v, ok := g.mainCache.peek("k")
+		return ok && v.String() == "3"
+	})
+
+	// 超过宽限期后同步加载
+	clock.advance(2 * time.Hour)
+	if s := get(); s != "4" {
+		t.Fatalf("Get = %q, want a blocking load", s)
+	}
+}
@@ -0,0 +1,452 @@
+// 写入数据源
+// Getter 同时实现 Setter 或 Deleter 时，所有者节点上的 Set 和 Remove 会先写入数据源再更新 mainCache（write-through），
+// 避免应用自行写数据源时与加载相互竞争。开启 write-behind 后写入先进入队列，由后台协程合并、分批写入并在失败时重试。
+
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Setter 是 Getter 的可选扩展，用于将 Set 的值写入数据源
+type Setter interface {
+	Set(ctx context.Context, key string, value []byte, expire time.Time) error
+}
+
+// Deleter 是 Getter 的可选扩展，用于从数据源删除 Remove 的键
+type Deleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// BatchWriter 是 Getter 的可选扩展，write-behind 模式下用于一次写入一批操作。
+// 未实现该接口时，每个操作分别调用 Setter.Set 或 Deleter.Delete。
+type BatchWriter interface {
+	WriteMany(ctx context.Context, ops []WriteOp) error
+}
+
+// WriteOp 是一次对数据源的写入，Delete 为 true 时表示删除 Key
+type WriteOp struct {
+	Key    string
+	Value  []byte
+	Expire time.Time
+	Delete bool
+}
+
+// WriteBehindOptions 是 write-behind 模式的配置，为零的字段使用默认值
+type WriteBehindOptions struct {
+	// QueueSize 是等待写入（包括正在写入）的键的数量上限，队列满时 Set 和 Remove 阻塞到有空位或 ctx 结束。
+	// 同一个键在写入之前的多次操作只保留最后一次。默认为 1024
+	QueueSize int
+
+	// BatchSize 是每批写入的操作数上限，默认为 64
+	BatchSize int
+
+	// FlushInterval 是队列未满一批时写入的间隔，默认为 100ms
+	FlushInterval time.Duration
+
+	// MaxRetries 是一批写入失败后的重试次数，重试仍然失败的操作会被丢弃并记录日志，默认为 3
+	MaxRetries int
+
+	// RetryBackoff 是第一次重试前的等待时间，之后每次翻倍并加入随机抖动，默认为 100ms
+	RetryBackoff time.Duration
+}
+
+// WithWriteBehind 开启 write-behind 模式：所有者节点上的 Set 和 Remove 在写入队列后立即更新缓存并返回，
+// 队列中的操作由后台协程写入数据源。关闭进程前应调用 Flush 或 FlushAll，确保队列中的操作已写入。
+// 后台协程在第一次写入时启动，DeregisterGroup 注销该组时写完队列中剩余的操作后退出。
+func WithWriteBehind(opts WriteBehindOptions) GroupOption {
+	return func(g *Group) {
+		if opts.QueueSize <= 0 {
+			opts.QueueSize = 1024
+		}
+		if opts.BatchSize <= 0 {
+			opts.BatchSize = 64
+		}
+		if opts.FlushInterval <= 0 {
+			opts.FlushInterval = 100 * time.Millisecond
+		}
+		if opts.MaxRetries <= 0 {
+			opts.MaxRetries = 3
+		}
+		if opts.RetryBackoff <= 0 {
+			opts.RetryBackoff = 100 * time.Millisecond
+		}
+		g.writeBehind = newWriteBehind(g, opts)
+	}
+}
+
+// Flush 等待 write-behind 队列中的操作全部写入数据源（包括因失败被丢弃），未开启 write-behind 时立即返回
+func (g *Group) Flush(ctx context.Context) error {
+	if g.writeBehind == nil {
+		return nil
+	}
+	return g.writeBehind.flush(ctx)
+}
+
+// FlushAll 对所有已注册的组调用 Flush，用于关闭进程之前
+func FlushAll(ctx context.Context) error {
+	var errs []error
+	for _, g := range allGroups() {
+		if err := g.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flush group %s: %w", g.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// storeWritable 判断 op 是否需要写入数据源，即 Getter 是否实现了对应的接口
+func (g *Group) storeWritable(op WriteOp) bool {
+	if _, ok := g.getter.(BatchWriter); ok && g.writeBehind != nil {
+		return true
+	}
+	if op.Delete {
+		_, ok := g.getter.(Deleter)
+		return ok
+	}
+	_, ok := g.getter.(Setter)
+	return ok
+}
+
+// getterWrites 判断 Getter 是否实现了任何写入数据源的接口
+func (g *Group) getterWrites() bool {
+	switch g.getter.(type) {
+	case Setter, Deleter, BatchWriter:
+		return true
+	default:
+		return false
+	}
+}
+
+// persist 将所有者节点上的 Set 或 Remove 写入数据源：write-behind 模式下加入队列，否则同步写入。
+// 调用方在 persist 成功之后再更新缓存。
+func (g *Group) persist(ctx context.Context, op WriteOp) error {
+	if !g.storeWritable(op) {
+		return nil
+	}
+	// 在更新缓存之前使正在进行的本地加载的结果失效，它们可能读到了写入之前的数据
+	atomic.AddUint64(&g.storeSeq, 1)
+	if g.writeBehind != nil {
+		return g.writeBehind.enqueue(ctx, op)
+	}
+	ctx, cancel := g.loadContext(ctx)
+	defer cancel()
+	if err := g.writeStore(ctx, op); err != nil {
+		g.Stats.StoreWriteErrs.Add(1)
+		return fmt.Errorf("write key '%s' to store: %w", op.Key, err)
+	}
+	g.Stats.StoreWrites.Add(1)
+	return nil
+}
+
+// writeStore 通过 Setter 或 Deleter 写入一个操作
+func (g *Group) writeStore(ctx context.Context, op WriteOp) error {
+	if op.Delete {
+		if d, ok := g.getter.(Deleter); ok {
+			return d.Delete(ctx, op.Key)
+		}
+		return nil
+	}
+	if s, ok := g.getter.(Setter); ok {
+		return s.Set(ctx, op.Key, op.Value, op.Expire)
+	}
+	return nil
+}
+
+// populateLoaded 将本地加载的 key 写入 mainCache。seq 是开始加载时的 storeSeq，
+// 加载期间有写入时，加载的结果可能早于写入，因此不再写入缓存。
+func (g *Group) populateLoaded(key string, value ByteView, seq uint64) {
+	// Getter 不写数据源时不会有写入，省去加锁
+	if !g.getterWrites() {
+		g.populateCache(key, value, &g.mainCache)
+		return
+	}
+	g.loadGroup.Lock(func() {
+		if atomic.LoadUint64(&g.storeSeq) == seq {
+			g.populateCache(key, value, &g.mainCache)
+		}
+	})
+}
+
+// getFromStore 调用 Getter 加载 key。write-behind 队列中有 key 尚未写入的操作时以它为准，
+// 因为数据源中还是旧值。
+func (g *Group) getFromStore(ctx context.Context, key string, dest Sink) error {
+	if g.writeBehind != nil {
+		if op, ok := g.writeBehind.lookup(key); ok {
+			if op.Delete {
+				return &ErrNotFound{Msg: "key '" + key + "' was removed"}
+			}
+			return dest.SetBytes(op.Value, op.Expire)
+		}
+	}
+	release, err := g.acquireLoad(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return g.getter.Get(ctx, key, dest)
+}
+
+// hasPendingWrites 判断 keys 中是否有尚未写入数据源的键
+func (g *Group) hasPendingWrites(keys []string) bool {
+	if g.writeBehind == nil {
+		return false
+	}
+	for _, key := range keys {
+		if _, ok := g.writeBehind.lookup(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBehind 是 write-behind 模式的写入队列
+type writeBehind struct {
+	g    *Group
+	opts WriteBehindOptions
+
+	mu sync.Mutex
+	// pending 是等待写入的操作，order 记录它们第一次入队的顺序
+	pending map[string]WriteOp
+	order   []string
+	// writing 是正在写入的一批操作
+	writing map[string]WriteOp
+	// waiters 在队列清空后被关闭，用于 flush
+	waiters []chan struct{}
+
+	// slots 的容量为 QueueSize，每个等待或正在写入的键占用一个位置
+	slots chan struct{}
+	// wake 用于在队列满一批或调用 flush 时立即唤醒后台协程
+	wake chan struct{}
+
+	// started 保证后台协程只启动一次，quit 关闭时它写完剩余的操作后退出；closed 之后不再接受新的操作
+	started sync.Once
+	quit    chan struct{}
+	closed  bool
+}
+
+func newWriteBehind(g *Group, opts WriteBehindOptions) *writeBehind {
+	w := &writeBehind{
+		g:       g,
+		opts:    opts,
+		pending: make(map[string]WriteOp),
+		writing: make(map[string]WriteOp),
+		slots:   make(chan struct{}, opts.QueueSize),
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	return w
+}
+
+// errWriteBehindClosed 表示组已经被注销，write-behind 队列不再接受新的操作
+var errWriteBehindClosed = errors.New("write-behind queue closed")
+
+// stop 停止后台协程，队列中剩余的操作在它退出前写入
+func (w *writeBehind) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	close(w.quit)
+}
+
+// enqueue 将 op 加入队列，同一个键尚未写入的操作会被 op 替换。队列满时阻塞到有空位或 ctx 结束。
+func (w *writeBehind) enqueue(ctx context.Context, op WriteOp) error {
+	w.started.Do(func() { go w.run() })
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return errWriteBehindClosed
+	}
+	if _, ok := w.pending[op.Key]; ok {
+		w.pending[op.Key] = op
+		w.mu.Unlock()
+		return nil
+	}
+	w.mu.Unlock()
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case w.slots <- struct{}{}:
+	case <-done:
+		return fmt.Errorf("write-behind queue full: %w", ctx.Err())
+	}
+
+	w.mu.Lock()
+	if _, ok := w.pending[op.Key]; ok {
+		// 等待空位期间同一个键已经入队，归还多占的位置
+		<-w.slots
+	} else {
+		w.order = append(w.order, op.Key)
+	}
+	w.pending[op.Key] = op
+	full := len(w.order) >= w.opts.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		w.signal()
+	}
+	return nil
+}
+
+// lookup 返回 key 尚未写入数据源的操作，加载时以它为准
+func (w *writeBehind) lookup(key string) (WriteOp, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if op, ok := w.pending[key]; ok {
+		return op, true
+	}
+	op, ok := w.writing[key]
+	return op, ok
+}
+
+// signal 唤醒后台协程
+func (w *writeBehind) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush 唤醒后台协程并等待队列清空
+func (w *writeBehind) flush(ctx context.Context) error {
+	w.mu.Lock()
+	if len(w.pending) == 0 && len(w.writing) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	w.waiters = append(w.waiters, ch)
+	w.mu.Unlock()
+
+	w.signal()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 每隔 FlushInterval 或被唤醒时，将队列中的操作分批写入数据源，quit 关闭后写完剩余的操作再退出
+func (w *writeBehind) run() {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		quit := false
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		case <-w.quit:
+			quit = true
+		}
+		for {
+			batch := w.take()
+			if len(batch) == 0 {
+				break
+			}
+			w.write(batch)
+			w.done(batch)
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// take 从队列中按入队顺序取出最多 BatchSize 个操作，标记为正在写入
+func (w *writeBehind) take() []WriteOp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(w.order)
+	if n > w.opts.BatchSize {
+		n = w.opts.BatchSize
+	}
+	batch := make([]WriteOp, 0, n)
+	for _, key := range w.order[:n] {
+		op := w.pending[key]
+		delete(w.pending, key)
+		w.writing[key] = op
+		batch = append(batch, op)
+	}
+	w.order = w.order[n:]
+	return batch
+}
+
+// done 在一批操作写入结束后归还位置，队列清空时通知等待 flush 的调用方
+func (w *writeBehind) done(batch []WriteOp) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, op := range batch {
+		delete(w.writing, op.Key)
+		<-w.slots
+	}
+	if len(w.pending) == 0 && len(w.writing) == 0 {
+		for _, ch := range w.waiters {
+			close(ch)
+		}
+		w.waiters = nil
+	}
+}
+
+// write 写入一批操作，失败的操作按指数退避重试，重试次数用尽后丢弃并记录日志
+func (w *writeBehind) write(batch []WriteOp) {
+	g := w.g
+	backoff := w.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		var err error
+		batch, err = w.writeOnce(batch)
+		if len(batch) == 0 {
+			return
+		}
+		if attempt >= w.opts.MaxRetries {
+			g.Stats.StoreWriteErrs.Add(int64(len(batch)))
+			if logger := g.getLogger(); logger != nil {
+				logger.Error().
+					WithFields(map[string]interface{}{
+						"err":      err,
+						"ops":      len(batch),
+						"category": "groupcache",
+					}).Printf("dropping write-behind operations after %d retries", w.opts.MaxRetries)
+			}
+			return
+		}
+		g.Stats.StoreWriteRetries.Add(1)
+		time.Sleep(jitter(backoff))
+		backoff *= 2
+	}
+}
+
+// writeOnce 写入一批操作，返回失败的操作和其中最后一个错误
+func (w *writeBehind) writeOnce(batch []WriteOp) ([]WriteOp, error) {
+	g := w.g
+	ctx, cancel := g.loadContext(context.Background())
+	defer cancel()
+	if bw, ok := g.getter.(BatchWriter); ok {
+		if err := bw.WriteMany(ctx, batch); err != nil {
+			return batch, err
+		}
+		g.Stats.StoreWrites.Add(int64(len(batch)))
+		return nil, nil
+	}
+	var failed []WriteOp
+	var lastErr error
+	for _, op := range batch {
+		if err := g.writeStore(ctx, op); err != nil {
+			failed = append(failed, op)
+			lastErr = err
+			continue
+		}
+		g.Stats.StoreWrites.Add(1)
+	}
+	return failed, lastErr
+}
@@ -0,0 +1,130 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore 是实现了 Setter 和 Deleter 的内存数据源，fail 大于零时接下来的 fail 次写入失败
+type memStore struct {
+	mu     sync.Mutex
+	data   map[string]string
+	writes int
+	fail   int
+}
+
+func (m *memStore) Get(ctx context.Context, key string, dest Sink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.data[key]
+	if !ok {
+		return &ErrNotFound{Msg: "no such key"}
+	}
+	return dest.SetString(v, time.Time{})
+}
+
+func (m *memStore) Set(ctx context.Context, key string, value []byte, expire time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail > 0 {
+		m.fail--
+		return errors.New("store down")
+	}
+	m.writes++
+	m.data[key] = string(value)
+	return nil
+}
+
+func (m *memStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memStore) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.data)
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	st := &memStore{data: map[string]string{"a": "1"}}
+	g := newTestGroup(t, st, WithPeerPicker(fixedPicker{}))
+
+	if err := g.Set(ctx, "a", []byte("2"), time.Time{}, false); err != nil || st.data["a"] != "2" {
+		t.Fatalf("Set = %v, store = %v", err, st.data)
+	}
+	// 写入数据源失败时不更新缓存
+	st.fail = 1
+	if err := g.Set(ctx, "a", []byte("3"), time.Time{}, false); err == nil {
+		t.Fatal("Set should fail when the store write fails")
+	}
+	var s string
+	if err := g.Get(ctx, "a", StringSink(&s)); err != nil || s != "2" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if err := g.Remove(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.data["a"]; ok {
+		t.Fatal("Remove did not delete from the store")
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	st := &memStore{data: map[string]string{}}
+	g := newTestGroup(t, st, WithPeerPicker(fixedPicker{}), WithWriteBehind(WriteBehindOptions{
+		QueueSize:     4,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	}))
+
+	for i := 0; i < 10; i++ {
+		if err := g.Set(ctx, string(rune('a'+i)), []byte("v"), time.Time{}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.mu.Lock()
+	st.fail = 2
+	st.mu.Unlock()
+	if err := g.Set(ctx, "z", []byte("zz"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	// 尚未写入数据源的值以队列为准
+	g.localRemove("z")
+	var s string
+	if err := g.Get(ctx, "z", StringSink(&s)); err != nil || s != "zz" {
+		t.Fatalf("Get of a pending write = %q, %v", s, err)
+	}
+	if err := g.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := st.len(); n != 11 || g.Stats.StoreWriteRetries.Get() == 0 {
+		t.Fatalf("store has %d keys, retries = %d", n, g.Stats.StoreWriteRetries.Get())
+	}
+}
+
+// 注销组时后台协程写完剩余的操作后退出，之后的写入返回错误
+func TestWriteBehindDeregister(t *testing.T) {
+	ctx := context.Background()
+	st := &memStore{data: map[string]string{}}
+	g := NewGroupWithOptions(t.Name(), st, WithPeerPicker(fixedPicker{}),
+		WithWriteBehind(WriteBehindOptions{FlushInterval: time.Hour}))
+
+	if err := g.Set(ctx, "a", []byte("1"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	DeregisterGroup(t.Name())
+	waitFor(t, "pending write", func() bool { return st.len() == 1 })
+
+	if err := g.Set(ctx, "b", []byte("2"), time.Time{}, false); !errors.Is(err, errWriteBehindClosed) {
+		t.Fatalf("Set after deregister = %v, want errWriteBehindClosed", err)
+	}
+}
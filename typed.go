@@ -0,0 +1,126 @@
+// 带类型的缓存组
+// TypedGroup[T] 在 Group 之上通过 Codec[T] 完成值的序列化和反序列化，
+// 使应用代码直接读写 T，而不必接触 Sink 和 ByteView。
+
+package geecache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 在 T 与缓存中保存的字节之间转换
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec 使用 encoding/json 编码
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用 encoding/gob 编码
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec 使用 protobuf 编码，T 为生成的消息指针类型，例如 *pb.GetRequest
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Marshal(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[T]) Unmarshal(data []byte) (T, error) {
+	// 通过零值（nil 指针）的反射信息创建一个新的消息
+	var zero T
+	v := zero.ProtoReflect().New().Interface().(T)
+	if err := proto.Unmarshal(data, v); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
+
+// LoaderFunc 在缓存未命中时加载 key 对应的值，expire 为零表示永不过期
+type LoaderFunc[T any] func(ctx context.Context, key string) (value T, expire time.Time, err error)
+
+// TypedGroup 是值类型为 T 的 Group
+type TypedGroup[T any] struct {
+	group *Group
+	codec Codec[T]
+}
+
+// NewTypedGroup 创建一个值类型为 T 的组，loader 加载的值通过 codec 编码后缓存。
+// opts 与 NewGroupWithOptions 相同。
+func NewTypedGroup[T any](name string, codec Codec[T], loader LoaderFunc[T], opts ...GroupOption) *TypedGroup[T] {
+	getter := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		v, expire, err := loader(ctx, key)
+		if err != nil {
+			return err
+		}
+		b, err := codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return dest.SetBytes(b, expire)
+	})
+	return &TypedGroup[T]{
+		group: NewGroupWithOptions(name, getter, opts...),
+		codec: codec,
+	}
+}
+
+// Group 返回底层的 Group，用于查看统计信息或进行其他配置
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get 返回 key 对应的值，缓存未命中时从所有者节点或 loader 加载
+func (t *TypedGroup[T]) Get(ctx context.Context, key string) (T, error) {
+	var view ByteView
+	if err := t.group.Get(ctx, key, ByteViewSink(&view)); err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.codec.Unmarshal(view.ByteSlice())
+}
+
+// Set 将 key 的值设置为 v，expire 为零表示永不过期
+func (t *TypedGroup[T]) Set(ctx context.Context, key string, v T, expire time.Time) error {
+	b, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.group.Set(ctx, key, b, expire, false)
+}
+
+// Remove 从所有节点的缓存中删除 key
+func (t *TypedGroup[T]) Remove(ctx context.Context, key string) error {
+	return t.group.Remove(ctx, key)
+}
@@ -0,0 +1,82 @@
+package geecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/CodingCaius/geecache/geecachepb"
+)
+
+type testUser struct {
+	Name string
+	Age  int
+}
+
+func newTestTypedGroup[T any](t *testing.T, suffix string, codec Codec[T], loader LoaderFunc[T], opts ...GroupOption) *TypedGroup[T] {
+	t.Helper()
+	name := strings.ReplaceAll(t.Name(), "/", "_") + suffix
+	tg := NewTypedGroup[T](name, codec, loader, opts...)
+	t.Cleanup(func() { DeregisterGroup(name) })
+	return tg
+}
+
+func TestTypedGroup(t *testing.T) {
+	loader := func(ctx context.Context, key string) (testUser, time.Time, error) {
+		if key == "missing" {
+			return testUser{}, time.Time{}, &ErrNotFound{Msg: "no such user"}
+		}
+		return testUser{Name: key, Age: 3}, time.Time{}, nil
+	}
+	ctx := context.Background()
+	for i, codec := range []Codec[testUser]{JSONCodec[testUser]{}, GobCodec[testUser]{}} {
+		tg := newTestTypedGroup(t, fmt.Sprint(i), codec, loader, WithPeerPicker(fixedPicker{}))
+		if u, err := tg.Get(ctx, "bob"); err != nil || u != (testUser{"bob", 3}) {
+			t.Fatalf("Get = %+v, %v", u, err)
+		}
+		if err := tg.Set(ctx, "al", testUser{"al", 9}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if u, err := tg.Get(ctx, "al"); err != nil || u.Age != 9 {
+			t.Fatalf("Get after Set = %+v, %v", u, err)
+		}
+		if err := tg.Remove(ctx, "al"); err != nil {
+			t.Fatal(err)
+		}
+		if u, err := tg.Get(ctx, "al"); err != nil || u.Age != 3 {
+			t.Fatalf("Get after Remove = %+v, %v", u, err)
+		}
+		if _, err := tg.Get(ctx, "missing"); !errors.Is(err, &ErrNotFound{}) {
+			t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+		}
+	}
+}
+
+// 所有者节点返回编码后的字节，本节点解码
+func TestTypedGroupFromPeer(t *testing.T) {
+	peer := &fakePeer{url: "owner", get: func(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+		b, err := json.Marshal(testUser{Name: in.Key, Age: 7})
+		out.Value = b
+		return err
+	}}
+	tg := newTestTypedGroup[testUser](t, "", JSONCodec[testUser]{}, func(ctx context.Context, key string) (testUser, time.Time, error) {
+		t.Fatalf("loader called for remote key %s", key)
+		return testUser{}, time.Time{}, nil
+	}, WithPeerPicker(fixedPicker{peer}))
+	if u, err := tg.Get(context.Background(), "eve"); err != nil || u != (testUser{"eve", 7}) {
+		t.Fatalf("Get = %+v, %v", u, err)
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	tg := newTestTypedGroup[*pb.GetRequest](t, "", ProtoCodec[*pb.GetRequest]{}, func(ctx context.Context, key string) (*pb.GetRequest, time.Time, error) {
+		return &pb.GetRequest{Group: "g", Key: key}, time.Time{}, nil
+	}, WithPeerPicker(fixedPicker{}))
+	if r, err := tg.Get(context.Background(), "kk"); err != nil || r.Key != "kk" || r.Group != "g" {
+		t.Fatalf("Get = %v, %v", r, err)
+	}
+}
@@ -2,8 +2,10 @@ package geecache
 
 import (
 	"fmt"
+	"math/rand"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // 显示错误时运行堆栈
@@ -33,4 +35,13 @@ func validPeerAddr(addr string) bool {
 		return false
 	}
 	return true
-}
\ No newline at end of file
+}
+
+// jitter 返回 [d/2, d) 之间的随机时长，避免多个节点同时重试
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}